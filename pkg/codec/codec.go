@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"gocv.io/x/gocv"
+)
+
+// ParseEncoding returns the encoding with the given name: png, jpeg, webp
+// or raw. PNG is used when no name is given.
+func ParseEncoding(name string) (pb.FrameEncoding, error) {
+	switch strings.ToLower(name) {
+	case "", "png":
+		return pb.FrameEncoding_FRAME_ENCODING_PNG, nil
+	case "jpeg", "jpg":
+		return pb.FrameEncoding_FRAME_ENCODING_JPEG, nil
+	case "webp":
+		return pb.FrameEncoding_FRAME_ENCODING_WEBP, nil
+	case "raw", "raw_bgr":
+		return pb.FrameEncoding_FRAME_ENCODING_RAW_BGR, nil
+	default:
+		return 0, fmt.Errorf("unknown frame encoding: %s", name)
+	}
+}
+
+// Encode encodes a BGR frame, quality applies to JPEG and WebP and the
+// default of OpenCV is used when it is not set
+func Encode(frame gocv.Mat, enc pb.FrameEncoding, quality int) ([]byte, error) {
+	var ext gocv.FileExt
+	var params []int
+	switch enc {
+	case pb.FrameEncoding_FRAME_ENCODING_UNSPECIFIED, pb.FrameEncoding_FRAME_ENCODING_PNG:
+		ext = gocv.PNGFileExt
+	case pb.FrameEncoding_FRAME_ENCODING_JPEG:
+		ext = gocv.JPEGFileExt
+		if quality > 0 {
+			params = []int{gocv.IMWriteJpegQuality, quality}
+		}
+	case pb.FrameEncoding_FRAME_ENCODING_WEBP:
+		ext = gocv.FileExt(".webp")
+		if quality > 0 {
+			params = []int{gocv.IMWriteWebpQuality, quality}
+		}
+	case pb.FrameEncoding_FRAME_ENCODING_RAW_BGR:
+		if frame.Type() != gocv.MatTypeCV8UC3 {
+			return nil, fmt.Errorf("raw encoding expects a BGR frame")
+		}
+		return frame.ToBytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown frame encoding: %v", enc)
+	}
+
+	buf, err := gocv.IMEncodeWithParams(ext, frame, params)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Close()
+	// the bytes belong to the native buffer, which is released on return
+	return bytes.Clone(buf.GetBytes()), nil
+}
+
+// Decode decodes the frame bytes according to the encoding in the metadata,
+// the returned image must be closed by the caller unless an error is returned
+func Decode(data []byte, md *pb.FrameMetadata) (gocv.Mat, error) {
+	if md.GetEncoding() != pb.FrameEncoding_FRAME_ENCODING_RAW_BGR {
+		img, err := gocv.IMDecode(data, gocv.IMReadColor)
+		if err != nil {
+			return gocv.Mat{}, err
+		}
+		// corrupt data is reported with an empty image rather than an error
+		if img.Empty() {
+			img.Close()
+			return gocv.Mat{}, fmt.Errorf("[%d] bytes cannot be decoded as %v", len(data), md.GetEncoding())
+		}
+		return img, nil
+	}
+	rows, cols := int(md.GetHeight()), int(md.GetWidth())
+	if rows <= 0 || cols <= 0 || len(data) != rows*cols*3 {
+		return gocv.Mat{}, fmt.Errorf("raw frame of [%d] bytes does not match the shape [%dx%d]", len(data), cols, rows)
+	}
+	return gocv.NewMatFromBytes(rows, cols, gocv.MatTypeCV8UC3, data)
+}
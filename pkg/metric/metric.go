@@ -25,6 +25,48 @@ var (
 			Help: "Gauge of round-trip times for different services.",
 		},
 		[]string{"service"})
+	sourceReconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "source_reconnects_total",
+			Help: "Number of reconnections to a video source.",
+		},
+		[]string{"source"})
+	sourceConnected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "source_connected",
+			Help: "Whether a video source is connected (1) or reconnecting (0).",
+		},
+		[]string{"source"})
+	framesSuppressed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "frames_suppressed_total",
+			Help: "Number of frames not sent downstream since they had no motion.",
+		},
+		[]string{"source"})
+	framesReordered = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "frames_reordered_total",
+			Help: "Number of frames held back until the earlier frames of their source were applied.",
+		},
+		[]string{"source"})
+	framesDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "frames_dropped_total",
+			Help: "Number of frames not applied, by reason.",
+		},
+		[]string{"source", "reason"})
+	modelInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "model_info",
+			Help: "The model used by the detector, set to 1 for the active model.",
+		},
+		[]string{"name", "version", "family"})
+	modelReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "model_reloads_total",
+			Help: "Number of model reloads, by result.",
+		},
+		[]string{"result"})
 )
 
 func (m *Metric) RegisterMetrics(sentDataBuckets, procTimeBuckets, rttTimeBuckets []float64) {
@@ -69,6 +111,13 @@ func (m *Metric) RegisterMetrics(sentDataBuckets, procTimeBuckets, rttTimeBucket
 	prometheus.MustRegister(rttTimeHistogram)
 	prometheus.MustRegister(procTime)
 	prometheus.MustRegister(rTTTimes)
+	prometheus.MustRegister(sourceReconnects)
+	prometheus.MustRegister(sourceConnected)
+	prometheus.MustRegister(framesSuppressed)
+	prometheus.MustRegister(framesReordered)
+	prometheus.MustRegister(framesDropped)
+	prometheus.MustRegister(modelInfo)
+	prometheus.MustRegister(modelReloads)
 }
 
 type Metric struct {
@@ -95,6 +144,63 @@ func (m *Metric) AddRttTime(s string, time float64) {
 	rTTTimes.WithLabelValues(s).Set(time)
 }
 
+func (m *Metric) AddReconnect(source string) {
+	m.lock()
+	defer m.unlock()
+	sourceReconnects.WithLabelValues(source).Inc()
+}
+
+func (m *Metric) SetSourceConnected(source string, connected bool) {
+	m.lock()
+	defer m.unlock()
+	if connected {
+		sourceConnected.WithLabelValues(source).Set(1)
+	} else {
+		sourceConnected.WithLabelValues(source).Set(0)
+	}
+}
+
+func (m *Metric) AddSuppressedFrame(source string) {
+	m.lock()
+	defer m.unlock()
+	framesSuppressed.WithLabelValues(source).Inc()
+}
+
+func (m *Metric) AddReorderedFrame(source string) {
+	m.lock()
+	defer m.unlock()
+	framesReordered.WithLabelValues(source).Inc()
+}
+
+func (m *Metric) AddDroppedFrame(source, reason string) {
+	m.lock()
+	defer m.unlock()
+	framesDropped.WithLabelValues(source, reason).Inc()
+}
+
+// SetActiveModel replaces the active model of the detector
+func (m *Metric) SetActiveModel(name, version, family string) {
+	m.lock()
+	defer m.unlock()
+	modelInfo.Reset()
+	modelInfo.WithLabelValues(name, version, family).Set(1)
+}
+
+func (m *Metric) AddModelReload(result string) {
+	m.lock()
+	defer m.unlock()
+	modelReloads.WithLabelValues(result).Inc()
+}
+
+// RemoveSource drops the metrics of a source that is no longer read
+func (m *Metric) RemoveSource(source string) {
+	m.lock()
+	defer m.unlock()
+	sourceReconnects.DeleteLabelValues(source)
+	sourceConnected.DeleteLabelValues(source)
+	framesSuppressed.DeleteLabelValues(source)
+}
+
 func (m *Metric) lock() {
 	m.mu.Lock()
 }
@@ -21,29 +21,1044 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Encoding of the frame bytes sent between the services
+type FrameEncoding int32
+
+const (
+	// Not set, a source profile uses the default of the aggregator and
+	// frames are encoded as PNG
+	FrameEncoding_FRAME_ENCODING_UNSPECIFIED FrameEncoding = 0
+	FrameEncoding_FRAME_ENCODING_PNG         FrameEncoding = 1
+	FrameEncoding_FRAME_ENCODING_JPEG        FrameEncoding = 2
+	FrameEncoding_FRAME_ENCODING_WEBP        FrameEncoding = 3
+	// Raw BGR pixels, the shape is given by width and height
+	FrameEncoding_FRAME_ENCODING_RAW_BGR FrameEncoding = 4
+)
+
+// Enum value maps for FrameEncoding.
+var (
+	FrameEncoding_name = map[int32]string{
+		0: "FRAME_ENCODING_UNSPECIFIED",
+		1: "FRAME_ENCODING_PNG",
+		2: "FRAME_ENCODING_JPEG",
+		3: "FRAME_ENCODING_WEBP",
+		4: "FRAME_ENCODING_RAW_BGR",
+	}
+	FrameEncoding_value = map[string]int32{
+		"FRAME_ENCODING_UNSPECIFIED": 0,
+		"FRAME_ENCODING_PNG":         1,
+		"FRAME_ENCODING_JPEG":        2,
+		"FRAME_ENCODING_WEBP":        3,
+		"FRAME_ENCODING_RAW_BGR":     4,
+	}
+)
+
+func (x FrameEncoding) Enum() *FrameEncoding {
+	p := new(FrameEncoding)
+	*p = x
+	return p
+}
+
+func (x FrameEncoding) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FrameEncoding) Descriptor() protoreflect.EnumDescriptor {
+	return file_detection_tracking_pipeline_proto_enumTypes[0].Descriptor()
+}
+
+func (FrameEncoding) Type() protoreflect.EnumType {
+	return &file_detection_tracking_pipeline_proto_enumTypes[0]
+}
+
+func (x FrameEncoding) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FrameEncoding.Descriptor instead.
+func (FrameEncoding) EnumDescriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{0}
+}
+
 type Data struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Payload       string                 `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
 	SentTimestamp string                 `protobuf:"bytes,2,opt,name=sent_timestamp,json=sentTimestamp,proto3" json:"sent_timestamp,omitempty"`
+	// Optional settings of a source registering with SendDataToServer
+	Profile       *SourceProfile `protobuf:"bytes,3,opt,name=profile,proto3" json:"profile,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Data) Reset() {
+	*x = Data{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Data) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data) ProtoMessage() {}
+
+func (x *Data) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data.ProtoReflect.Descriptor instead.
+func (*Data) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Data) GetPayload() string {
+	if x != nil {
+		return x.Payload
+	}
+	return ""
+}
+
+func (x *Data) GetSentTimestamp() string {
+	if x != nil {
+		return x.SentTimestamp
+	}
+	return ""
+}
+
+func (x *Data) GetProfile() *SourceProfile {
+	if x != nil {
+		return x.Profile
+	}
+	return nil
+}
+
+// Per-source settings, unset fields fall back to the aggregator defaults
+type SourceProfile struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ImageWidth         int32                  `protobuf:"varint,1,opt,name=image_width,json=imageWidth,proto3" json:"image_width,omitempty"`
+	ImageHeight        int32                  `protobuf:"varint,2,opt,name=image_height,json=imageHeight,proto3" json:"image_height,omitempty"`
+	FrameRate          float64                `protobuf:"fixed64,3,opt,name=frame_rate,json=frameRate,proto3" json:"frame_rate,omitempty"`
+	DetectionFrequency int32                  `protobuf:"varint,4,opt,name=detection_frequency,json=detectionFrequency,proto3" json:"detection_frequency,omitempty"`
+	QueueSize          int32                  `protobuf:"varint,5,opt,name=queue_size,json=queueSize,proto3" json:"queue_size,omitempty"`
+	MaxTotalFrames     int32                  `protobuf:"varint,6,opt,name=max_total_frames,json=maxTotalFrames,proto3" json:"max_total_frames,omitempty"`
+	StreamPath         string                 `protobuf:"bytes,7,opt,name=stream_path,json=streamPath,proto3" json:"stream_path,omitempty"`
+	Username           string                 `protobuf:"bytes,8,opt,name=username,proto3" json:"username,omitempty"`
+	Password           string                 `protobuf:"bytes,9,opt,name=password,proto3" json:"password,omitempty"`
+	Encoding           FrameEncoding          `protobuf:"varint,10,opt,name=encoding,proto3,enum=detection_tracking_system.FrameEncoding" json:"encoding,omitempty"`
+	// Quality of JPEG and WebP encoding, from 1 to 100
+	EncodingQuality int32 `protobuf:"varint,11,opt,name=encoding_quality,json=encodingQuality,proto3" json:"encoding_quality,omitempty"`
+	// Sliced inference of the detector, the detector default is used if unset
+	Tiling        *TilingConfig `protobuf:"bytes,12,opt,name=tiling,proto3" json:"tiling,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SourceProfile) Reset() {
+	*x = SourceProfile{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SourceProfile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SourceProfile) ProtoMessage() {}
+
+func (x *SourceProfile) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SourceProfile.ProtoReflect.Descriptor instead.
+func (*SourceProfile) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SourceProfile) GetImageWidth() int32 {
+	if x != nil {
+		return x.ImageWidth
+	}
+	return 0
+}
+
+func (x *SourceProfile) GetImageHeight() int32 {
+	if x != nil {
+		return x.ImageHeight
+	}
+	return 0
+}
+
+func (x *SourceProfile) GetFrameRate() float64 {
+	if x != nil {
+		return x.FrameRate
+	}
+	return 0
+}
+
+func (x *SourceProfile) GetDetectionFrequency() int32 {
+	if x != nil {
+		return x.DetectionFrequency
+	}
+	return 0
+}
+
+func (x *SourceProfile) GetQueueSize() int32 {
+	if x != nil {
+		return x.QueueSize
+	}
+	return 0
+}
+
+func (x *SourceProfile) GetMaxTotalFrames() int32 {
+	if x != nil {
+		return x.MaxTotalFrames
+	}
+	return 0
+}
+
+func (x *SourceProfile) GetStreamPath() string {
+	if x != nil {
+		return x.StreamPath
+	}
+	return ""
+}
+
+func (x *SourceProfile) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *SourceProfile) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *SourceProfile) GetEncoding() FrameEncoding {
+	if x != nil {
+		return x.Encoding
+	}
+	return FrameEncoding_FRAME_ENCODING_UNSPECIFIED
+}
+
+func (x *SourceProfile) GetEncodingQuality() int32 {
+	if x != nil {
+		return x.EncodingQuality
+	}
+	return 0
+}
+
+func (x *SourceProfile) GetTiling() *TilingConfig {
+	if x != nil {
+		return x.Tiling
+	}
+	return nil
+}
+
+// Sliced inference runs the detector over overlapping tiles of the frame in
+// addition to the full frame, so small objects are not lost when the frame
+// is scaled down to the input of the model. The frames of a source with
+// tiling enabled are sent at their native resolution.
+type TilingConfig struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Enabled bool                   `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	// Size of the tiles in pixels, the input size of the model if unset
+	TileWidth  int32 `protobuf:"varint,2,opt,name=tile_width,json=tileWidth,proto3" json:"tile_width,omitempty"`
+	TileHeight int32 `protobuf:"varint,3,opt,name=tile_height,json=tileHeight,proto3" json:"tile_height,omitempty"`
+	// Overlap of neighbouring tiles as a fraction of the tile size
+	Overlap float64 `protobuf:"fixed64,4,opt,name=overlap,proto3" json:"overlap,omitempty"`
+	// How the detections of the tiles and the full frame are merged, "nms"
+	// or "wbf" (weighted box fusion)
+	Merge         string `protobuf:"bytes,5,opt,name=merge,proto3" json:"merge,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TilingConfig) Reset() {
+	*x = TilingConfig{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TilingConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TilingConfig) ProtoMessage() {}
+
+func (x *TilingConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TilingConfig.ProtoReflect.Descriptor instead.
+func (*TilingConfig) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TilingConfig) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *TilingConfig) GetTileWidth() int32 {
+	if x != nil {
+		return x.TileWidth
+	}
+	return 0
+}
+
+func (x *TilingConfig) GetTileHeight() int32 {
+	if x != nil {
+		return x.TileHeight
+	}
+	return 0
+}
+
+func (x *TilingConfig) GetOverlap() float64 {
+	if x != nil {
+		return x.Overlap
+	}
+	return 0
+}
+
+func (x *TilingConfig) GetMerge() string {
+	if x != nil {
+		return x.Merge
+	}
+	return ""
+}
+
+type RegisterSourceRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional name, used as the source id when set
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Full URL of the stream, e.g. rtsp://host:port/stream
+	StreamUrl string `protobuf:"bytes,2,opt,name=stream_url,json=streamUrl,proto3" json:"stream_url,omitempty"`
+	// Preferred RTSP transport: "tcp" or "udp", empty for the default
+	Transport string `protobuf:"bytes,3,opt,name=transport,proto3" json:"transport,omitempty"`
+	// Codec of the stream, e.g. "h264"
+	Codec  string            `protobuf:"bytes,4,opt,name=codec,proto3" json:"codec,omitempty"`
+	Labels map[string]string `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Resolution and other settings, unset fields use the defaults
+	Profile       *SourceProfile `protobuf:"bytes,6,opt,name=profile,proto3" json:"profile,omitempty"`
+	SentTimestamp string         `protobuf:"bytes,7,opt,name=sent_timestamp,json=sentTimestamp,proto3" json:"sent_timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterSourceRequest) Reset() {
+	*x = RegisterSourceRequest{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterSourceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterSourceRequest) ProtoMessage() {}
+
+func (x *RegisterSourceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterSourceRequest.ProtoReflect.Descriptor instead.
+func (*RegisterSourceRequest) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RegisterSourceRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RegisterSourceRequest) GetStreamUrl() string {
+	if x != nil {
+		return x.StreamUrl
+	}
+	return ""
+}
+
+func (x *RegisterSourceRequest) GetTransport() string {
+	if x != nil {
+		return x.Transport
+	}
+	return ""
+}
+
+func (x *RegisterSourceRequest) GetCodec() string {
+	if x != nil {
+		return x.Codec
+	}
+	return ""
+}
+
+func (x *RegisterSourceRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *RegisterSourceRequest) GetProfile() *SourceProfile {
+	if x != nil {
+		return x.Profile
+	}
+	return nil
+}
+
+func (x *RegisterSourceRequest) GetSentTimestamp() string {
+	if x != nil {
+		return x.SentTimestamp
+	}
+	return ""
+}
+
+type RegisterSourceResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Status   string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	SourceId string                 `protobuf:"bytes,2,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	// Effective configuration of the source, without credentials
+	Config                *SourceProfile `protobuf:"bytes,3,opt,name=config,proto3" json:"config,omitempty"`
+	LeaseSeconds          int64          `protobuf:"varint,4,opt,name=lease_seconds,json=leaseSeconds,proto3" json:"lease_seconds,omitempty"`
+	OriginalSentTimestamp string         `protobuf:"bytes,5,opt,name=original_sent_timestamp,json=originalSentTimestamp,proto3" json:"original_sent_timestamp,omitempty"`
+	ReceivedTimestamp     string         `protobuf:"bytes,6,opt,name=received_timestamp,json=receivedTimestamp,proto3" json:"received_timestamp,omitempty"`
+	AckSentTimestamp      string         `protobuf:"bytes,7,opt,name=ack_sent_timestamp,json=ackSentTimestamp,proto3" json:"ack_sent_timestamp,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *RegisterSourceResponse) Reset() {
+	*x = RegisterSourceResponse{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterSourceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterSourceResponse) ProtoMessage() {}
+
+func (x *RegisterSourceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterSourceResponse.ProtoReflect.Descriptor instead.
+func (*RegisterSourceResponse) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RegisterSourceResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *RegisterSourceResponse) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
+	}
+	return ""
+}
+
+func (x *RegisterSourceResponse) GetConfig() *SourceProfile {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *RegisterSourceResponse) GetLeaseSeconds() int64 {
+	if x != nil {
+		return x.LeaseSeconds
+	}
+	return 0
+}
+
+func (x *RegisterSourceResponse) GetOriginalSentTimestamp() string {
+	if x != nil {
+		return x.OriginalSentTimestamp
+	}
+	return ""
+}
+
+func (x *RegisterSourceResponse) GetReceivedTimestamp() string {
+	if x != nil {
+		return x.ReceivedTimestamp
+	}
+	return ""
+}
+
+func (x *RegisterSourceResponse) GetAckSentTimestamp() string {
+	if x != nil {
+		return x.AckSentTimestamp
+	}
+	return ""
+}
+
+type FrameMetadata struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp string                 `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	SourceId  string                 `protobuf:"bytes,2,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	FrameId   int64                  `protobuf:"varint,3,opt,name=frame_id,json=frameId,proto3" json:"frame_id,omitempty"`
+	Encoding  FrameEncoding          `protobuf:"varint,4,opt,name=encoding,proto3,enum=detection_tracking_system.FrameEncoding" json:"encoding,omitempty"`
+	Width     int32                  `protobuf:"varint,5,opt,name=width,proto3" json:"width,omitempty"`
+	Height    int32                  `protobuf:"varint,6,opt,name=height,proto3" json:"height,omitempty"`
+	// Id of the frame of the source sent downstream before this one, zero
+	// for the first frame of a stream
+	PreviousFrameId int64 `protobuf:"varint,7,opt,name=previous_frame_id,json=previousFrameId,proto3" json:"previous_frame_id,omitempty"`
+	// Sliced inference settings of the source, set by the aggregator
+	Tiling        *TilingConfig `protobuf:"bytes,8,opt,name=tiling,proto3" json:"tiling,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FrameMetadata) Reset() {
+	*x = FrameMetadata{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FrameMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FrameMetadata) ProtoMessage() {}
+
+func (x *FrameMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FrameMetadata.ProtoReflect.Descriptor instead.
+func (*FrameMetadata) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *FrameMetadata) GetTimestamp() string {
+	if x != nil {
+		return x.Timestamp
+	}
+	return ""
+}
+
+func (x *FrameMetadata) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
+	}
+	return ""
+}
+
+func (x *FrameMetadata) GetFrameId() int64 {
+	if x != nil {
+		return x.FrameId
+	}
+	return 0
+}
+
+func (x *FrameMetadata) GetEncoding() FrameEncoding {
+	if x != nil {
+		return x.Encoding
+	}
+	return FrameEncoding_FRAME_ENCODING_UNSPECIFIED
+}
+
+func (x *FrameMetadata) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *FrameMetadata) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *FrameMetadata) GetPreviousFrameId() int64 {
+	if x != nil {
+		return x.PreviousFrameId
+	}
+	return 0
+}
+
+func (x *FrameMetadata) GetTiling() *TilingConfig {
+	if x != nil {
+		return x.Tiling
+	}
+	return nil
+}
+
+type BoundingBox struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	XMin          int32                  `protobuf:"varint,1,opt,name=x_min,json=xMin,proto3" json:"x_min,omitempty"`
+	YMin          int32                  `protobuf:"varint,2,opt,name=y_min,json=yMin,proto3" json:"y_min,omitempty"`
+	XMax          int32                  `protobuf:"varint,3,opt,name=x_max,json=xMax,proto3" json:"x_max,omitempty"`
+	YMax          int32                  `protobuf:"varint,4,opt,name=y_max,json=yMax,proto3" json:"y_max,omitempty"`
+	ClassId       int32                  `protobuf:"varint,5,opt,name=class_id,json=classId,proto3" json:"class_id,omitempty"`
+	Label         string                 `protobuf:"bytes,6,opt,name=label,proto3" json:"label,omitempty"`
+	Confidence    float32                `protobuf:"fixed32,7,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BoundingBox) Reset() {
+	*x = BoundingBox{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BoundingBox) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoundingBox) ProtoMessage() {}
+
+func (x *BoundingBox) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoundingBox.ProtoReflect.Descriptor instead.
+func (*BoundingBox) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *BoundingBox) GetXMin() int32 {
+	if x != nil {
+		return x.XMin
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetYMin() int32 {
+	if x != nil {
+		return x.YMin
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetXMax() int32 {
+	if x != nil {
+		return x.XMax
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetYMax() int32 {
+	if x != nil {
+		return x.YMax
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetClassId() int32 {
+	if x != nil {
+		return x.ClassId
+	}
+	return 0
+}
+
+func (x *BoundingBox) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *BoundingBox) GetConfidence() float32 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+type DetectionResult struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Metadata *FrameMetadata         `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Boxes    []*BoundingBox         `protobuf:"bytes,2,rep,name=boxes,proto3" json:"boxes,omitempty"`
+	// Name and version of the model that detected the boxes
+	ModelName     string `protobuf:"bytes,3,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
+	ModelVersion  string `protobuf:"bytes,4,opt,name=model_version,json=modelVersion,proto3" json:"model_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DetectionResult) Reset() {
+	*x = DetectionResult{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DetectionResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DetectionResult) ProtoMessage() {}
+
+func (x *DetectionResult) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DetectionResult.ProtoReflect.Descriptor instead.
+func (*DetectionResult) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DetectionResult) GetMetadata() *FrameMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *DetectionResult) GetBoxes() []*BoundingBox {
+	if x != nil {
+		return x.Boxes
+	}
+	return nil
+}
+
+func (x *DetectionResult) GetModelName() string {
+	if x != nil {
+		return x.ModelName
+	}
+	return ""
+}
+
+func (x *DetectionResult) GetModelVersion() string {
+	if x != nil {
+		return x.ModelVersion
+	}
+	return ""
+}
+
+type ModelInfo struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Status          string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Name            string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Version         string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Family          string                 `protobuf:"bytes,4,opt,name=family,proto3" json:"family,omitempty"`
+	LoadedTimestamp string                 `protobuf:"bytes,5,opt,name=loaded_timestamp,json=loadedTimestamp,proto3" json:"loaded_timestamp,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ModelInfo) Reset() {
+	*x = ModelInfo{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelInfo) ProtoMessage() {}
+
+func (x *ModelInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelInfo.ProtoReflect.Descriptor instead.
+func (*ModelInfo) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ModelInfo) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetFamily() string {
+	if x != nil {
+		return x.Family
+	}
+	return ""
+}
+
+func (x *ModelInfo) GetLoadedTimestamp() string {
+	if x != nil {
+		return x.LoadedTimestamp
+	}
+	return ""
+}
+
+type DetectionResponse struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	Status                string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Detection             *DetectionResult       `protobuf:"bytes,2,opt,name=detection,proto3" json:"detection,omitempty"`
+	OriginalSentTimestamp string                 `protobuf:"bytes,3,opt,name=original_sent_timestamp,json=originalSentTimestamp,proto3" json:"original_sent_timestamp,omitempty"`
+	ReceivedTimestamp     string                 `protobuf:"bytes,4,opt,name=received_timestamp,json=receivedTimestamp,proto3" json:"received_timestamp,omitempty"`
+	AckSentTimestamp      string                 `protobuf:"bytes,5,opt,name=ack_sent_timestamp,json=ackSentTimestamp,proto3" json:"ack_sent_timestamp,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *DetectionResponse) Reset() {
+	*x = DetectionResponse{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DetectionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DetectionResponse) ProtoMessage() {}
+
+func (x *DetectionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DetectionResponse.ProtoReflect.Descriptor instead.
+func (*DetectionResponse) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DetectionResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *DetectionResponse) GetDetection() *DetectionResult {
+	if x != nil {
+		return x.Detection
+	}
+	return nil
+}
+
+func (x *DetectionResponse) GetOriginalSentTimestamp() string {
+	if x != nil {
+		return x.OriginalSentTimestamp
+	}
+	return ""
+}
+
+func (x *DetectionResponse) GetReceivedTimestamp() string {
+	if x != nil {
+		return x.ReceivedTimestamp
+	}
+	return ""
+}
+
+func (x *DetectionResponse) GetAckSentTimestamp() string {
+	if x != nil {
+		return x.AckSentTimestamp
+	}
+	return ""
+}
+
+type FrameData struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metadata      *FrameMetadata         `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	FrameData     []byte                 `protobuf:"bytes,2,opt,name=frame_data,json=frameData,proto3" json:"frame_data,omitempty"`
+	SentTimestamp string                 `protobuf:"bytes,3,opt,name=sent_timestamp,json=sentTimestamp,proto3" json:"sent_timestamp,omitempty"`
+	// Set by the detector when forwarding a frame to the tracker
+	Detection     *DetectionResult `protobuf:"bytes,4,opt,name=detection,proto3" json:"detection,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FrameData) Reset() {
+	*x = FrameData{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FrameData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FrameData) ProtoMessage() {}
+
+func (x *FrameData) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FrameData.ProtoReflect.Descriptor instead.
+func (*FrameData) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FrameData) GetMetadata() *FrameMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *FrameData) GetFrameData() []byte {
+	if x != nil {
+		return x.FrameData
+	}
+	return nil
+}
+
+func (x *FrameData) GetSentTimestamp() string {
+	if x != nil {
+		return x.SentTimestamp
+	}
+	return ""
+}
+
+func (x *FrameData) GetDetection() *DetectionResult {
+	if x != nil {
+		return x.Detection
+	}
+	return nil
+}
+
+type TrackingStateRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	SourceId string                 `protobuf:"bytes,1,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	// Only the tracks with these labels are returned, all tracks if empty
+	Labels        []string `protobuf:"bytes,2,rep,name=labels,proto3" json:"labels,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Data) Reset() {
-	*x = Data{}
-	mi := &file_detection_tracking_pipeline_proto_msgTypes[0]
+func (x *TrackingStateRequest) Reset() {
+	*x = TrackingStateRequest{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Data) String() string {
+func (x *TrackingStateRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Data) ProtoMessage() {}
+func (*TrackingStateRequest) ProtoMessage() {}
 
-func (x *Data) ProtoReflect() protoreflect.Message {
-	mi := &file_detection_tracking_pipeline_proto_msgTypes[0]
+func (x *TrackingStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -54,49 +1069,208 @@ func (x *Data) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Data.ProtoReflect.Descriptor instead.
-func (*Data) Descriptor() ([]byte, []int) {
-	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use TrackingStateRequest.ProtoReflect.Descriptor instead.
+func (*TrackingStateRequest) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *Data) GetPayload() string {
+func (x *TrackingStateRequest) GetSourceId() string {
 	if x != nil {
-		return x.Payload
+		return x.SourceId
 	}
 	return ""
 }
 
-func (x *Data) GetSentTimestamp() string {
+func (x *TrackingStateRequest) GetLabels() []string {
 	if x != nil {
-		return x.SentTimestamp
+		return x.Labels
+	}
+	return nil
+}
+
+type Track struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	TrackId          int64                  `protobuf:"varint,1,opt,name=track_id,json=trackId,proto3" json:"track_id,omitempty"`
+	Box              *BoundingBox           `protobuf:"bytes,2,opt,name=box,proto3" json:"box,omitempty"`
+	FirstSeenFrameId int64                  `protobuf:"varint,3,opt,name=first_seen_frame_id,json=firstSeenFrameId,proto3" json:"first_seen_frame_id,omitempty"`
+	LastSeenFrameId  int64                  `protobuf:"varint,4,opt,name=last_seen_frame_id,json=lastSeenFrameId,proto3" json:"last_seen_frame_id,omitempty"`
+	Hits             int32                  `protobuf:"varint,5,opt,name=hits,proto3" json:"hits,omitempty"`
+	Misses           int32                  `protobuf:"varint,6,opt,name=misses,proto3" json:"misses,omitempty"`
+	Age              int32                  `protobuf:"varint,7,opt,name=age,proto3" json:"age,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Track) Reset() {
+	*x = Track{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Track) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Track) ProtoMessage() {}
+
+func (x *Track) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Track.ProtoReflect.Descriptor instead.
+func (*Track) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Track) GetTrackId() int64 {
+	if x != nil {
+		return x.TrackId
+	}
+	return 0
+}
+
+func (x *Track) GetBox() *BoundingBox {
+	if x != nil {
+		return x.Box
+	}
+	return nil
+}
+
+func (x *Track) GetFirstSeenFrameId() int64 {
+	if x != nil {
+		return x.FirstSeenFrameId
+	}
+	return 0
+}
+
+func (x *Track) GetLastSeenFrameId() int64 {
+	if x != nil {
+		return x.LastSeenFrameId
+	}
+	return 0
+}
+
+func (x *Track) GetHits() int32 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+func (x *Track) GetMisses() int32 {
+	if x != nil {
+		return x.Misses
+	}
+	return 0
+}
+
+func (x *Track) GetAge() int32 {
+	if x != nil {
+		return x.Age
+	}
+	return 0
+}
+
+type SourceTracks struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	SourceId string                 `protobuf:"bytes,1,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	// Id of the last frame that updated the tracks of the source
+	LastFrameId      int64    `protobuf:"varint,2,opt,name=last_frame_id,json=lastFrameId,proto3" json:"last_frame_id,omitempty"`
+	UpdatedTimestamp string   `protobuf:"bytes,3,opt,name=updated_timestamp,json=updatedTimestamp,proto3" json:"updated_timestamp,omitempty"`
+	Tracks           []*Track `protobuf:"bytes,4,rep,name=tracks,proto3" json:"tracks,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *SourceTracks) Reset() {
+	*x = SourceTracks{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SourceTracks) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SourceTracks) ProtoMessage() {}
+
+func (x *SourceTracks) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SourceTracks.ProtoReflect.Descriptor instead.
+func (*SourceTracks) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SourceTracks) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
 	}
 	return ""
 }
 
-type FrameData struct {
+func (x *SourceTracks) GetLastFrameId() int64 {
+	if x != nil {
+		return x.LastFrameId
+	}
+	return 0
+}
+
+func (x *SourceTracks) GetUpdatedTimestamp() string {
+	if x != nil {
+		return x.UpdatedTimestamp
+	}
+	return ""
+}
+
+func (x *SourceTracks) GetTracks() []*Track {
+	if x != nil {
+		return x.Tracks
+	}
+	return nil
+}
+
+type TrackingState struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Metadata      string                 `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
-	FrameData     []byte                 `protobuf:"bytes,2,opt,name=frame_data,json=frameData,proto3" json:"frame_data,omitempty"`
-	SentTimestamp string                 `protobuf:"bytes,3,opt,name=sent_timestamp,json=sentTimestamp,proto3" json:"sent_timestamp,omitempty"`
+	Sources       []*SourceTracks        `protobuf:"bytes,1,rep,name=sources,proto3" json:"sources,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *FrameData) Reset() {
-	*x = FrameData{}
-	mi := &file_detection_tracking_pipeline_proto_msgTypes[1]
+func (x *TrackingState) Reset() {
+	*x = TrackingState{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *FrameData) String() string {
+func (x *TrackingState) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*FrameData) ProtoMessage() {}
+func (*TrackingState) ProtoMessage() {}
 
-func (x *FrameData) ProtoReflect() protoreflect.Message {
-	mi := &file_detection_tracking_pipeline_proto_msgTypes[1]
+func (x *TrackingState) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -107,32 +1281,88 @@ func (x *FrameData) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use FrameData.ProtoReflect.Descriptor instead.
-func (*FrameData) Descriptor() ([]byte, []int) {
-	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use TrackingState.ProtoReflect.Descriptor instead.
+func (*TrackingState) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *FrameData) GetMetadata() string {
+func (x *TrackingState) GetSources() []*SourceTracks {
 	if x != nil {
-		return x.Metadata
+		return x.Sources
+	}
+	return nil
+}
+
+// Tracking output of a single processed frame
+type TrackingResult struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	SourceId string                 `protobuf:"bytes,1,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	FrameId  int64                  `protobuf:"varint,2,opt,name=frame_id,json=frameId,proto3" json:"frame_id,omitempty"`
+	// Capture time of the frame set by the aggregator
+	Timestamp     string   `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Tracks        []*Track `protobuf:"bytes,4,rep,name=tracks,proto3" json:"tracks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TrackingResult) Reset() {
+	*x = TrackingResult{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TrackingResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrackingResult) ProtoMessage() {}
+
+func (x *TrackingResult) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrackingResult.ProtoReflect.Descriptor instead.
+func (*TrackingResult) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *TrackingResult) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
 	}
 	return ""
 }
 
-func (x *FrameData) GetFrameData() []byte {
+func (x *TrackingResult) GetFrameId() int64 {
 	if x != nil {
-		return x.FrameData
+		return x.FrameId
 	}
-	return nil
+	return 0
 }
 
-func (x *FrameData) GetSentTimestamp() string {
+func (x *TrackingResult) GetTimestamp() string {
 	if x != nil {
-		return x.SentTimestamp
+		return x.Timestamp
 	}
 	return ""
 }
 
+func (x *TrackingResult) GetTracks() []*Track {
+	if x != nil {
+		return x.Tracks
+	}
+	return nil
+}
+
 type DataResponse struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	Status            string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
@@ -145,7 +1375,7 @@ type DataResponse struct {
 
 func (x *DataResponse) Reset() {
 	*x = DataResponse{}
-	mi := &file_detection_tracking_pipeline_proto_msgTypes[2]
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -157,7 +1387,7 @@ func (x *DataResponse) String() string {
 func (*DataResponse) ProtoMessage() {}
 
 func (x *DataResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_detection_tracking_pipeline_proto_msgTypes[2]
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -170,7 +1400,7 @@ func (x *DataResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DataResponse.ProtoReflect.Descriptor instead.
 func (*DataResponse) Descriptor() ([]byte, []int) {
-	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{2}
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *DataResponse) GetStatus() string {
@@ -207,13 +1437,21 @@ type Ack struct {
 	OriginalSentTimestamp string                 `protobuf:"bytes,2,opt,name=original_sent_timestamp,json=originalSentTimestamp,proto3" json:"original_sent_timestamp,omitempty"`
 	ReceivedTimestamp     string                 `protobuf:"bytes,3,opt,name=received_timestamp,json=receivedTimestamp,proto3" json:"received_timestamp,omitempty"`
 	AckSentTimestamp      string                 `protobuf:"bytes,4,opt,name=ack_sent_timestamp,json=ackSentTimestamp,proto3" json:"ack_sent_timestamp,omitempty"`
-	unknownFields         protoimpl.UnknownFields
-	sizeCache             protoimpl.SizeCache
+	FrameId               int64                  `protobuf:"varint,5,opt,name=frame_id,json=frameId,proto3" json:"frame_id,omitempty"`
+	SourceId              string                 `protobuf:"bytes,6,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	// Set by the tracker, the latest state of the tracks of the source
+	Health *TrackerHealth `protobuf:"bytes,7,opt,name=health,proto3" json:"health,omitempty"`
+	// Set by the detector, the detections of the frame
+	Detection *DetectionResult `protobuf:"bytes,8,opt,name=detection,proto3" json:"detection,omitempty"`
+	// Set by the detector when it has sent the frame to the tracker itself
+	Forwarded     bool `protobuf:"varint,9,opt,name=forwarded,proto3" json:"forwarded,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Ack) Reset() {
 	*x = Ack{}
-	mi := &file_detection_tracking_pipeline_proto_msgTypes[3]
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -225,7 +1463,7 @@ func (x *Ack) String() string {
 func (*Ack) ProtoMessage() {}
 
 func (x *Ack) ProtoReflect() protoreflect.Message {
-	mi := &file_detection_tracking_pipeline_proto_msgTypes[3]
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -238,7 +1476,7 @@ func (x *Ack) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Ack.ProtoReflect.Descriptor instead.
 func (*Ack) Descriptor() ([]byte, []int) {
-	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{3}
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *Ack) GetStatus() string {
@@ -269,34 +1507,273 @@ func (x *Ack) GetAckSentTimestamp() string {
 	return ""
 }
 
+func (x *Ack) GetFrameId() int64 {
+	if x != nil {
+		return x.FrameId
+	}
+	return 0
+}
+
+func (x *Ack) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
+	}
+	return ""
+}
+
+func (x *Ack) GetHealth() *TrackerHealth {
+	if x != nil {
+		return x.Health
+	}
+	return nil
+}
+
+func (x *Ack) GetDetection() *DetectionResult {
+	if x != nil {
+		return x.Detection
+	}
+	return nil
+}
+
+func (x *Ack) GetForwarded() bool {
+	if x != nil {
+		return x.Forwarded
+	}
+	return false
+}
+
+type TrackerHealth struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	ActiveTracks int32                  `protobuf:"varint,1,opt,name=active_tracks,json=activeTracks,proto3" json:"active_tracks,omitempty"`
+	// Tracks that were not found in their latest update
+	LostTracks int32 `protobuf:"varint,2,opt,name=lost_tracks,json=lostTracks,proto3" json:"lost_tracks,omitempty"`
+	// Mean confidence of the latest detections of the tracks
+	MeanConfidence float32 `protobuf:"fixed32,3,opt,name=mean_confidence,json=meanConfidence,proto3" json:"mean_confidence,omitempty"`
+	// Mean displacement of the tracks in pixels per frame
+	MeanMotion    float32 `protobuf:"fixed32,4,opt,name=mean_motion,json=meanMotion,proto3" json:"mean_motion,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TrackerHealth) Reset() {
+	*x = TrackerHealth{}
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TrackerHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrackerHealth) ProtoMessage() {}
+
+func (x *TrackerHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_detection_tracking_pipeline_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrackerHealth.ProtoReflect.Descriptor instead.
+func (*TrackerHealth) Descriptor() ([]byte, []int) {
+	return file_detection_tracking_pipeline_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *TrackerHealth) GetActiveTracks() int32 {
+	if x != nil {
+		return x.ActiveTracks
+	}
+	return 0
+}
+
+func (x *TrackerHealth) GetLostTracks() int32 {
+	if x != nil {
+		return x.LostTracks
+	}
+	return 0
+}
+
+func (x *TrackerHealth) GetMeanConfidence() float32 {
+	if x != nil {
+		return x.MeanConfidence
+	}
+	return 0
+}
+
+func (x *TrackerHealth) GetMeanMotion() float32 {
+	if x != nil {
+		return x.MeanMotion
+	}
+	return 0
+}
+
 var File_detection_tracking_pipeline_proto protoreflect.FileDescriptor
 
 const file_detection_tracking_pipeline_proto_rawDesc = "" +
 	"\n" +
-	"!detection_tracking_pipeline.proto\x12\x19detection_tracking_system\"G\n" +
+	"!detection_tracking_pipeline.proto\x12\x19detection_tracking_system\"\x8b\x01\n" +
 	"\x04Data\x12\x18\n" +
 	"\apayload\x18\x01 \x01(\tR\apayload\x12%\n" +
-	"\x0esent_timestamp\x18\x02 \x01(\tR\rsentTimestamp\"m\n" +
-	"\tFrameData\x12\x1a\n" +
-	"\bmetadata\x18\x01 \x01(\tR\bmetadata\x12\x1d\n" +
+	"\x0esent_timestamp\x18\x02 \x01(\tR\rsentTimestamp\x12B\n" +
+	"\aprofile\x18\x03 \x01(\v2(.detection_tracking_system.SourceProfileR\aprofile\"\xf7\x03\n" +
+	"\rSourceProfile\x12\x1f\n" +
+	"\vimage_width\x18\x01 \x01(\x05R\n" +
+	"imageWidth\x12!\n" +
+	"\fimage_height\x18\x02 \x01(\x05R\vimageHeight\x12\x1d\n" +
+	"\n" +
+	"frame_rate\x18\x03 \x01(\x01R\tframeRate\x12/\n" +
+	"\x13detection_frequency\x18\x04 \x01(\x05R\x12detectionFrequency\x12\x1d\n" +
+	"\n" +
+	"queue_size\x18\x05 \x01(\x05R\tqueueSize\x12(\n" +
+	"\x10max_total_frames\x18\x06 \x01(\x05R\x0emaxTotalFrames\x12\x1f\n" +
+	"\vstream_path\x18\a \x01(\tR\n" +
+	"streamPath\x12\x1a\n" +
+	"\busername\x18\b \x01(\tR\busername\x12\x1a\n" +
+	"\bpassword\x18\t \x01(\tR\bpassword\x12D\n" +
+	"\bencoding\x18\n" +
+	" \x01(\x0e2(.detection_tracking_system.FrameEncodingR\bencoding\x12)\n" +
+	"\x10encoding_quality\x18\v \x01(\x05R\x0fencodingQuality\x12?\n" +
+	"\x06tiling\x18\f \x01(\v2'.detection_tracking_system.TilingConfigR\x06tiling\"\x98\x01\n" +
+	"\fTilingConfig\x12\x18\n" +
+	"\aenabled\x18\x01 \x01(\bR\aenabled\x12\x1d\n" +
+	"\n" +
+	"tile_width\x18\x02 \x01(\x05R\ttileWidth\x12\x1f\n" +
+	"\vtile_height\x18\x03 \x01(\x05R\n" +
+	"tileHeight\x12\x18\n" +
+	"\aoverlap\x18\x04 \x01(\x01R\aoverlap\x12\x14\n" +
+	"\x05merge\x18\x05 \x01(\tR\x05merge\"\xfa\x02\n" +
+	"\x15RegisterSourceRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"stream_url\x18\x02 \x01(\tR\tstreamUrl\x12\x1c\n" +
+	"\ttransport\x18\x03 \x01(\tR\ttransport\x12\x14\n" +
+	"\x05codec\x18\x04 \x01(\tR\x05codec\x12T\n" +
+	"\x06labels\x18\x05 \x03(\v2<.detection_tracking_system.RegisterSourceRequest.LabelsEntryR\x06labels\x12B\n" +
+	"\aprofile\x18\x06 \x01(\v2(.detection_tracking_system.SourceProfileR\aprofile\x12%\n" +
+	"\x0esent_timestamp\x18\a \x01(\tR\rsentTimestamp\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xc9\x02\n" +
+	"\x16RegisterSourceResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1b\n" +
+	"\tsource_id\x18\x02 \x01(\tR\bsourceId\x12@\n" +
+	"\x06config\x18\x03 \x01(\v2(.detection_tracking_system.SourceProfileR\x06config\x12#\n" +
+	"\rlease_seconds\x18\x04 \x01(\x03R\fleaseSeconds\x126\n" +
+	"\x17original_sent_timestamp\x18\x05 \x01(\tR\x15originalSentTimestamp\x12-\n" +
+	"\x12received_timestamp\x18\x06 \x01(\tR\x11receivedTimestamp\x12,\n" +
+	"\x12ack_sent_timestamp\x18\a \x01(\tR\x10ackSentTimestamp\"\xc6\x02\n" +
+	"\rFrameMetadata\x12\x1c\n" +
+	"\ttimestamp\x18\x01 \x01(\tR\ttimestamp\x12\x1b\n" +
+	"\tsource_id\x18\x02 \x01(\tR\bsourceId\x12\x19\n" +
+	"\bframe_id\x18\x03 \x01(\x03R\aframeId\x12D\n" +
+	"\bencoding\x18\x04 \x01(\x0e2(.detection_tracking_system.FrameEncodingR\bencoding\x12\x14\n" +
+	"\x05width\x18\x05 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x06 \x01(\x05R\x06height\x12*\n" +
+	"\x11previous_frame_id\x18\a \x01(\x03R\x0fpreviousFrameId\x12?\n" +
+	"\x06tiling\x18\b \x01(\v2'.detection_tracking_system.TilingConfigR\x06tiling\"\xb2\x01\n" +
+	"\vBoundingBox\x12\x13\n" +
+	"\x05x_min\x18\x01 \x01(\x05R\x04xMin\x12\x13\n" +
+	"\x05y_min\x18\x02 \x01(\x05R\x04yMin\x12\x13\n" +
+	"\x05x_max\x18\x03 \x01(\x05R\x04xMax\x12\x13\n" +
+	"\x05y_max\x18\x04 \x01(\x05R\x04yMax\x12\x19\n" +
+	"\bclass_id\x18\x05 \x01(\x05R\aclassId\x12\x14\n" +
+	"\x05label\x18\x06 \x01(\tR\x05label\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\a \x01(\x02R\n" +
+	"confidence\"\xd9\x01\n" +
+	"\x0fDetectionResult\x12D\n" +
+	"\bmetadata\x18\x01 \x01(\v2(.detection_tracking_system.FrameMetadataR\bmetadata\x12<\n" +
+	"\x05boxes\x18\x02 \x03(\v2&.detection_tracking_system.BoundingBoxR\x05boxes\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x03 \x01(\tR\tmodelName\x12#\n" +
+	"\rmodel_version\x18\x04 \x01(\tR\fmodelVersion\"\x94\x01\n" +
+	"\tModelInfo\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12\x16\n" +
+	"\x06family\x18\x04 \x01(\tR\x06family\x12)\n" +
+	"\x10loaded_timestamp\x18\x05 \x01(\tR\x0floadedTimestamp\"\x8a\x02\n" +
+	"\x11DetectionResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12H\n" +
+	"\tdetection\x18\x02 \x01(\v2*.detection_tracking_system.DetectionResultR\tdetection\x126\n" +
+	"\x17original_sent_timestamp\x18\x03 \x01(\tR\x15originalSentTimestamp\x12-\n" +
+	"\x12received_timestamp\x18\x04 \x01(\tR\x11receivedTimestamp\x12,\n" +
+	"\x12ack_sent_timestamp\x18\x05 \x01(\tR\x10ackSentTimestamp\"\xe1\x01\n" +
+	"\tFrameData\x12D\n" +
+	"\bmetadata\x18\x01 \x01(\v2(.detection_tracking_system.FrameMetadataR\bmetadata\x12\x1d\n" +
 	"\n" +
 	"frame_data\x18\x02 \x01(\fR\tframeData\x12%\n" +
-	"\x0esent_timestamp\x18\x03 \x01(\tR\rsentTimestamp\"\x96\x01\n" +
+	"\x0esent_timestamp\x18\x03 \x01(\tR\rsentTimestamp\x12H\n" +
+	"\tdetection\x18\x04 \x01(\v2*.detection_tracking_system.DetectionResultR\tdetection\"K\n" +
+	"\x14TrackingStateRequest\x12\x1b\n" +
+	"\tsource_id\x18\x01 \x01(\tR\bsourceId\x12\x16\n" +
+	"\x06labels\x18\x02 \x03(\tR\x06labels\"\xf6\x01\n" +
+	"\x05Track\x12\x19\n" +
+	"\btrack_id\x18\x01 \x01(\x03R\atrackId\x128\n" +
+	"\x03box\x18\x02 \x01(\v2&.detection_tracking_system.BoundingBoxR\x03box\x12-\n" +
+	"\x13first_seen_frame_id\x18\x03 \x01(\x03R\x10firstSeenFrameId\x12+\n" +
+	"\x12last_seen_frame_id\x18\x04 \x01(\x03R\x0flastSeenFrameId\x12\x12\n" +
+	"\x04hits\x18\x05 \x01(\x05R\x04hits\x12\x16\n" +
+	"\x06misses\x18\x06 \x01(\x05R\x06misses\x12\x10\n" +
+	"\x03age\x18\a \x01(\x05R\x03age\"\xb6\x01\n" +
+	"\fSourceTracks\x12\x1b\n" +
+	"\tsource_id\x18\x01 \x01(\tR\bsourceId\x12\"\n" +
+	"\rlast_frame_id\x18\x02 \x01(\x03R\vlastFrameId\x12+\n" +
+	"\x11updated_timestamp\x18\x03 \x01(\tR\x10updatedTimestamp\x128\n" +
+	"\x06tracks\x18\x04 \x03(\v2 .detection_tracking_system.TrackR\x06tracks\"R\n" +
+	"\rTrackingState\x12A\n" +
+	"\asources\x18\x01 \x03(\v2'.detection_tracking_system.SourceTracksR\asources\"\xa0\x01\n" +
+	"\x0eTrackingResult\x12\x1b\n" +
+	"\tsource_id\x18\x01 \x01(\tR\bsourceId\x12\x19\n" +
+	"\bframe_id\x18\x02 \x01(\x03R\aframeId\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\tR\ttimestamp\x128\n" +
+	"\x06tracks\x18\x04 \x03(\v2 .detection_tracking_system.TrackR\x06tracks\"\x96\x01\n" +
 	"\fDataResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
 	"\apayload\x18\x02 \x01(\tR\apayload\x12-\n" +
 	"\x12received_timestamp\x18\x03 \x01(\tR\x11receivedTimestamp\x12%\n" +
-	"\x0esent_timestamp\x18\x04 \x01(\tR\rsentTimestamp\"\xb2\x01\n" +
+	"\x0esent_timestamp\x18\x04 \x01(\tR\rsentTimestamp\"\x94\x03\n" +
 	"\x03Ack\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\x126\n" +
 	"\x17original_sent_timestamp\x18\x02 \x01(\tR\x15originalSentTimestamp\x12-\n" +
 	"\x12received_timestamp\x18\x03 \x01(\tR\x11receivedTimestamp\x12,\n" +
-	"\x12ack_sent_timestamp\x18\x04 \x01(\tR\x10ackSentTimestamp2\xe5\x03\n" +
+	"\x12ack_sent_timestamp\x18\x04 \x01(\tR\x10ackSentTimestamp\x12\x19\n" +
+	"\bframe_id\x18\x05 \x01(\x03R\aframeId\x12\x1b\n" +
+	"\tsource_id\x18\x06 \x01(\tR\bsourceId\x12@\n" +
+	"\x06health\x18\a \x01(\v2(.detection_tracking_system.TrackerHealthR\x06health\x12H\n" +
+	"\tdetection\x18\b \x01(\v2*.detection_tracking_system.DetectionResultR\tdetection\x12\x1c\n" +
+	"\tforwarded\x18\t \x01(\bR\tforwarded\"\x9f\x01\n" +
+	"\rTrackerHealth\x12#\n" +
+	"\ractive_tracks\x18\x01 \x01(\x05R\factiveTracks\x12\x1f\n" +
+	"\vlost_tracks\x18\x02 \x01(\x05R\n" +
+	"lostTracks\x12'\n" +
+	"\x0fmean_confidence\x18\x03 \x01(\x02R\x0emeanConfidence\x12\x1f\n" +
+	"\vmean_motion\x18\x04 \x01(\x02R\n" +
+	"meanMotion*\x95\x01\n" +
+	"\rFrameEncoding\x12\x1e\n" +
+	"\x1aFRAME_ENCODING_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12FRAME_ENCODING_PNG\x10\x01\x12\x17\n" +
+	"\x13FRAME_ENCODING_JPEG\x10\x02\x12\x17\n" +
+	"\x13FRAME_ENCODING_WEBP\x10\x03\x12\x1a\n" +
+	"\x16FRAME_ENCODING_RAW_BGR\x10\x042\xa4\t\n" +
 	"\x19DetectionTrackingPipeline\x12S\n" +
-	"\x10SendDataToServer\x12\x1f.detection_tracking_system.Data\x1a\x1e.detection_tracking_system.Ack\x12Y\n" +
+	"\x10SendDataToServer\x12\x1f.detection_tracking_system.Data\x1a\x1e.detection_tracking_system.Ack\x12u\n" +
+	"\x0eRegisterSource\x120.detection_tracking_system.RegisterSourceRequest\x1a1.detection_tracking_system.RegisterSourceResponse\x12S\n" +
+	"\x10UnregisterSource\x12\x1f.detection_tracking_system.Data\x1a\x1e.detection_tracking_system.Ack\x12Y\n" +
 	"\x11SendFrameToServer\x12$.detection_tracking_system.FrameData\x1a\x1e.detection_tracking_system.Ack\x12a\n" +
 	"\x19SendDetectedFrameToServer\x12$.detection_tracking_system.FrameData\x1a\x1e.detection_tracking_system.Ack\x12a\n" +
-	"\x15ReceiveDataFromServer\x12\x1f.detection_tracking_system.Data\x1a'.detection_tracking_system.DataResponse\x12R\n" +
+	"\vDetectFrame\x12$.detection_tracking_system.FrameData\x1a,.detection_tracking_system.DetectionResponse\x12T\n" +
+	"\vReloadModel\x12\x1f.detection_tracking_system.Data\x1a$.detection_tracking_system.ModelInfo\x12X\n" +
+	"\fStreamFrames\x12$.detection_tracking_system.FrameData\x1a\x1e.detection_tracking_system.Ack(\x010\x01\x12a\n" +
+	"\x15ReceiveDataFromServer\x12\x1f.detection_tracking_system.Data\x1a'.detection_tracking_system.DataResponse\x12m\n" +
+	"\x10GetTrackingState\x12/.detection_tracking_system.TrackingStateRequest\x1a(.detection_tracking_system.TrackingState\x12o\n" +
+	"\x0fSubscribeTracks\x12/.detection_tracking_system.TrackingStateRequest\x1a).detection_tracking_system.TrackingResult0\x01\x12R\n" +
 	"\x0fCheckConnection\x12\x1f.detection_tracking_system.Data\x1a\x1e.detection_tracking_system.AckB5Z3github.com/etesami/detection-tracking-system/protocb\x06proto3"
 
 var (
@@ -311,29 +1788,80 @@ func file_detection_tracking_pipeline_proto_rawDescGZIP() []byte {
 	return file_detection_tracking_pipeline_proto_rawDescData
 }
 
-var file_detection_tracking_pipeline_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_detection_tracking_pipeline_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_detection_tracking_pipeline_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_detection_tracking_pipeline_proto_goTypes = []any{
-	(*Data)(nil),         // 0: detection_tracking_system.Data
-	(*FrameData)(nil),    // 1: detection_tracking_system.FrameData
-	(*DataResponse)(nil), // 2: detection_tracking_system.DataResponse
-	(*Ack)(nil),          // 3: detection_tracking_system.Ack
+	(FrameEncoding)(0),             // 0: detection_tracking_system.FrameEncoding
+	(*Data)(nil),                   // 1: detection_tracking_system.Data
+	(*SourceProfile)(nil),          // 2: detection_tracking_system.SourceProfile
+	(*TilingConfig)(nil),           // 3: detection_tracking_system.TilingConfig
+	(*RegisterSourceRequest)(nil),  // 4: detection_tracking_system.RegisterSourceRequest
+	(*RegisterSourceResponse)(nil), // 5: detection_tracking_system.RegisterSourceResponse
+	(*FrameMetadata)(nil),          // 6: detection_tracking_system.FrameMetadata
+	(*BoundingBox)(nil),            // 7: detection_tracking_system.BoundingBox
+	(*DetectionResult)(nil),        // 8: detection_tracking_system.DetectionResult
+	(*ModelInfo)(nil),              // 9: detection_tracking_system.ModelInfo
+	(*DetectionResponse)(nil),      // 10: detection_tracking_system.DetectionResponse
+	(*FrameData)(nil),              // 11: detection_tracking_system.FrameData
+	(*TrackingStateRequest)(nil),   // 12: detection_tracking_system.TrackingStateRequest
+	(*Track)(nil),                  // 13: detection_tracking_system.Track
+	(*SourceTracks)(nil),           // 14: detection_tracking_system.SourceTracks
+	(*TrackingState)(nil),          // 15: detection_tracking_system.TrackingState
+	(*TrackingResult)(nil),         // 16: detection_tracking_system.TrackingResult
+	(*DataResponse)(nil),           // 17: detection_tracking_system.DataResponse
+	(*Ack)(nil),                    // 18: detection_tracking_system.Ack
+	(*TrackerHealth)(nil),          // 19: detection_tracking_system.TrackerHealth
+	nil,                            // 20: detection_tracking_system.RegisterSourceRequest.LabelsEntry
 }
 var file_detection_tracking_pipeline_proto_depIdxs = []int32{
-	0, // 0: detection_tracking_system.DetectionTrackingPipeline.SendDataToServer:input_type -> detection_tracking_system.Data
-	1, // 1: detection_tracking_system.DetectionTrackingPipeline.SendFrameToServer:input_type -> detection_tracking_system.FrameData
-	1, // 2: detection_tracking_system.DetectionTrackingPipeline.SendDetectedFrameToServer:input_type -> detection_tracking_system.FrameData
-	0, // 3: detection_tracking_system.DetectionTrackingPipeline.ReceiveDataFromServer:input_type -> detection_tracking_system.Data
-	0, // 4: detection_tracking_system.DetectionTrackingPipeline.CheckConnection:input_type -> detection_tracking_system.Data
-	3, // 5: detection_tracking_system.DetectionTrackingPipeline.SendDataToServer:output_type -> detection_tracking_system.Ack
-	3, // 6: detection_tracking_system.DetectionTrackingPipeline.SendFrameToServer:output_type -> detection_tracking_system.Ack
-	3, // 7: detection_tracking_system.DetectionTrackingPipeline.SendDetectedFrameToServer:output_type -> detection_tracking_system.Ack
-	2, // 8: detection_tracking_system.DetectionTrackingPipeline.ReceiveDataFromServer:output_type -> detection_tracking_system.DataResponse
-	3, // 9: detection_tracking_system.DetectionTrackingPipeline.CheckConnection:output_type -> detection_tracking_system.Ack
-	5, // [5:10] is the sub-list for method output_type
-	0, // [0:5] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2,  // 0: detection_tracking_system.Data.profile:type_name -> detection_tracking_system.SourceProfile
+	0,  // 1: detection_tracking_system.SourceProfile.encoding:type_name -> detection_tracking_system.FrameEncoding
+	3,  // 2: detection_tracking_system.SourceProfile.tiling:type_name -> detection_tracking_system.TilingConfig
+	20, // 3: detection_tracking_system.RegisterSourceRequest.labels:type_name -> detection_tracking_system.RegisterSourceRequest.LabelsEntry
+	2,  // 4: detection_tracking_system.RegisterSourceRequest.profile:type_name -> detection_tracking_system.SourceProfile
+	2,  // 5: detection_tracking_system.RegisterSourceResponse.config:type_name -> detection_tracking_system.SourceProfile
+	0,  // 6: detection_tracking_system.FrameMetadata.encoding:type_name -> detection_tracking_system.FrameEncoding
+	3,  // 7: detection_tracking_system.FrameMetadata.tiling:type_name -> detection_tracking_system.TilingConfig
+	6,  // 8: detection_tracking_system.DetectionResult.metadata:type_name -> detection_tracking_system.FrameMetadata
+	7,  // 9: detection_tracking_system.DetectionResult.boxes:type_name -> detection_tracking_system.BoundingBox
+	8,  // 10: detection_tracking_system.DetectionResponse.detection:type_name -> detection_tracking_system.DetectionResult
+	6,  // 11: detection_tracking_system.FrameData.metadata:type_name -> detection_tracking_system.FrameMetadata
+	8,  // 12: detection_tracking_system.FrameData.detection:type_name -> detection_tracking_system.DetectionResult
+	7,  // 13: detection_tracking_system.Track.box:type_name -> detection_tracking_system.BoundingBox
+	13, // 14: detection_tracking_system.SourceTracks.tracks:type_name -> detection_tracking_system.Track
+	14, // 15: detection_tracking_system.TrackingState.sources:type_name -> detection_tracking_system.SourceTracks
+	13, // 16: detection_tracking_system.TrackingResult.tracks:type_name -> detection_tracking_system.Track
+	19, // 17: detection_tracking_system.Ack.health:type_name -> detection_tracking_system.TrackerHealth
+	8,  // 18: detection_tracking_system.Ack.detection:type_name -> detection_tracking_system.DetectionResult
+	1,  // 19: detection_tracking_system.DetectionTrackingPipeline.SendDataToServer:input_type -> detection_tracking_system.Data
+	4,  // 20: detection_tracking_system.DetectionTrackingPipeline.RegisterSource:input_type -> detection_tracking_system.RegisterSourceRequest
+	1,  // 21: detection_tracking_system.DetectionTrackingPipeline.UnregisterSource:input_type -> detection_tracking_system.Data
+	11, // 22: detection_tracking_system.DetectionTrackingPipeline.SendFrameToServer:input_type -> detection_tracking_system.FrameData
+	11, // 23: detection_tracking_system.DetectionTrackingPipeline.SendDetectedFrameToServer:input_type -> detection_tracking_system.FrameData
+	11, // 24: detection_tracking_system.DetectionTrackingPipeline.DetectFrame:input_type -> detection_tracking_system.FrameData
+	1,  // 25: detection_tracking_system.DetectionTrackingPipeline.ReloadModel:input_type -> detection_tracking_system.Data
+	11, // 26: detection_tracking_system.DetectionTrackingPipeline.StreamFrames:input_type -> detection_tracking_system.FrameData
+	1,  // 27: detection_tracking_system.DetectionTrackingPipeline.ReceiveDataFromServer:input_type -> detection_tracking_system.Data
+	12, // 28: detection_tracking_system.DetectionTrackingPipeline.GetTrackingState:input_type -> detection_tracking_system.TrackingStateRequest
+	12, // 29: detection_tracking_system.DetectionTrackingPipeline.SubscribeTracks:input_type -> detection_tracking_system.TrackingStateRequest
+	1,  // 30: detection_tracking_system.DetectionTrackingPipeline.CheckConnection:input_type -> detection_tracking_system.Data
+	18, // 31: detection_tracking_system.DetectionTrackingPipeline.SendDataToServer:output_type -> detection_tracking_system.Ack
+	5,  // 32: detection_tracking_system.DetectionTrackingPipeline.RegisterSource:output_type -> detection_tracking_system.RegisterSourceResponse
+	18, // 33: detection_tracking_system.DetectionTrackingPipeline.UnregisterSource:output_type -> detection_tracking_system.Ack
+	18, // 34: detection_tracking_system.DetectionTrackingPipeline.SendFrameToServer:output_type -> detection_tracking_system.Ack
+	18, // 35: detection_tracking_system.DetectionTrackingPipeline.SendDetectedFrameToServer:output_type -> detection_tracking_system.Ack
+	10, // 36: detection_tracking_system.DetectionTrackingPipeline.DetectFrame:output_type -> detection_tracking_system.DetectionResponse
+	9,  // 37: detection_tracking_system.DetectionTrackingPipeline.ReloadModel:output_type -> detection_tracking_system.ModelInfo
+	18, // 38: detection_tracking_system.DetectionTrackingPipeline.StreamFrames:output_type -> detection_tracking_system.Ack
+	17, // 39: detection_tracking_system.DetectionTrackingPipeline.ReceiveDataFromServer:output_type -> detection_tracking_system.DataResponse
+	15, // 40: detection_tracking_system.DetectionTrackingPipeline.GetTrackingState:output_type -> detection_tracking_system.TrackingState
+	16, // 41: detection_tracking_system.DetectionTrackingPipeline.SubscribeTracks:output_type -> detection_tracking_system.TrackingResult
+	18, // 42: detection_tracking_system.DetectionTrackingPipeline.CheckConnection:output_type -> detection_tracking_system.Ack
+	31, // [31:43] is the sub-list for method output_type
+	19, // [19:31] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
 }
 
 func init() { file_detection_tracking_pipeline_proto_init() }
@@ -346,13 +1874,14 @@ func file_detection_tracking_pipeline_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_detection_tracking_pipeline_proto_rawDesc), len(file_detection_tracking_pipeline_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_detection_tracking_pipeline_proto_goTypes,
 		DependencyIndexes: file_detection_tracking_pipeline_proto_depIdxs,
+		EnumInfos:         file_detection_tracking_pipeline_proto_enumTypes,
 		MessageInfos:      file_detection_tracking_pipeline_proto_msgTypes,
 	}.Build()
 	File_detection_tracking_pipeline_proto = out.File
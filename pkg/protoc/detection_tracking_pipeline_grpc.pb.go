@@ -20,9 +20,16 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	DetectionTrackingPipeline_SendDataToServer_FullMethodName          = "/detection_tracking_system.DetectionTrackingPipeline/SendDataToServer"
+	DetectionTrackingPipeline_RegisterSource_FullMethodName            = "/detection_tracking_system.DetectionTrackingPipeline/RegisterSource"
+	DetectionTrackingPipeline_UnregisterSource_FullMethodName          = "/detection_tracking_system.DetectionTrackingPipeline/UnregisterSource"
 	DetectionTrackingPipeline_SendFrameToServer_FullMethodName         = "/detection_tracking_system.DetectionTrackingPipeline/SendFrameToServer"
 	DetectionTrackingPipeline_SendDetectedFrameToServer_FullMethodName = "/detection_tracking_system.DetectionTrackingPipeline/SendDetectedFrameToServer"
+	DetectionTrackingPipeline_DetectFrame_FullMethodName               = "/detection_tracking_system.DetectionTrackingPipeline/DetectFrame"
+	DetectionTrackingPipeline_ReloadModel_FullMethodName               = "/detection_tracking_system.DetectionTrackingPipeline/ReloadModel"
+	DetectionTrackingPipeline_StreamFrames_FullMethodName              = "/detection_tracking_system.DetectionTrackingPipeline/StreamFrames"
 	DetectionTrackingPipeline_ReceiveDataFromServer_FullMethodName     = "/detection_tracking_system.DetectionTrackingPipeline/ReceiveDataFromServer"
+	DetectionTrackingPipeline_GetTrackingState_FullMethodName          = "/detection_tracking_system.DetectionTrackingPipeline/GetTrackingState"
+	DetectionTrackingPipeline_SubscribeTracks_FullMethodName           = "/detection_tracking_system.DetectionTrackingPipeline/SubscribeTracks"
 	DetectionTrackingPipeline_CheckConnection_FullMethodName           = "/detection_tracking_system.DetectionTrackingPipeline/CheckConnection"
 )
 
@@ -33,10 +40,31 @@ type DetectionTrackingPipelineClient interface {
 	// A simple RPC to send data to the server
 	// and receive an acknowledgment
 	SendDataToServer(ctx context.Context, in *Data, opts ...grpc.CallOption) (*Ack, error)
+	// Registers a source or renews its lease, the response carries the
+	// assigned source id and the configuration used for the source
+	RegisterSource(ctx context.Context, in *RegisterSourceRequest, opts ...grpc.CallOption) (*RegisterSourceResponse, error)
+	// Removes a source, the payload carries the source id returned by
+	// RegisterSource or the host:port sent to SendDataToServer
+	UnregisterSource(ctx context.Context, in *Data, opts ...grpc.CallOption) (*Ack, error)
 	SendFrameToServer(ctx context.Context, in *FrameData, opts ...grpc.CallOption) (*Ack, error)
 	SendDetectedFrameToServer(ctx context.Context, in *FrameData, opts ...grpc.CallOption) (*Ack, error)
+	// Runs the detector on a frame and returns the detections to the
+	// caller, the frame is not forwarded to the tracker
+	DetectFrame(ctx context.Context, in *FrameData, opts ...grpc.CallOption) (*DetectionResponse, error)
+	// Loads the model manifest of the detector again and swaps the models
+	// once the new ones are loaded, frames in flight finish on the old models
+	ReloadModel(ctx context.Context, in *Data, opts ...grpc.CallOption) (*ModelInfo, error)
+	// A long-lived stream of frames, each frame is acknowledged with an Ack
+	// carrying its source and frame id so the sender can match them
+	StreamFrames(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[FrameData, Ack], error)
 	// A simple RPC to request data from the local storage
 	ReceiveDataFromServer(ctx context.Context, in *Data, opts ...grpc.CallOption) (*DataResponse, error)
+	// Returns the active tracks of a source, or of all sources when
+	// no source id is given
+	GetTrackingState(ctx context.Context, in *TrackingStateRequest, opts ...grpc.CallOption) (*TrackingState, error)
+	// Streams the tracking result of every processed frame of a source,
+	// or of all sources when no source id is given
+	SubscribeTracks(ctx context.Context, in *TrackingStateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TrackingResult], error)
 	// A simple RPC to send a ping to the service and receive a pong primarily for
 	// testing the connection and latency
 	CheckConnection(ctx context.Context, in *Data, opts ...grpc.CallOption) (*Ack, error)
@@ -60,6 +88,26 @@ func (c *detectionTrackingPipelineClient) SendDataToServer(ctx context.Context,
 	return out, nil
 }
 
+func (c *detectionTrackingPipelineClient) RegisterSource(ctx context.Context, in *RegisterSourceRequest, opts ...grpc.CallOption) (*RegisterSourceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterSourceResponse)
+	err := c.cc.Invoke(ctx, DetectionTrackingPipeline_RegisterSource_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *detectionTrackingPipelineClient) UnregisterSource(ctx context.Context, in *Data, opts ...grpc.CallOption) (*Ack, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, DetectionTrackingPipeline_UnregisterSource_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *detectionTrackingPipelineClient) SendFrameToServer(ctx context.Context, in *FrameData, opts ...grpc.CallOption) (*Ack, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Ack)
@@ -80,6 +128,39 @@ func (c *detectionTrackingPipelineClient) SendDetectedFrameToServer(ctx context.
 	return out, nil
 }
 
+func (c *detectionTrackingPipelineClient) DetectFrame(ctx context.Context, in *FrameData, opts ...grpc.CallOption) (*DetectionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DetectionResponse)
+	err := c.cc.Invoke(ctx, DetectionTrackingPipeline_DetectFrame_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *detectionTrackingPipelineClient) ReloadModel(ctx context.Context, in *Data, opts ...grpc.CallOption) (*ModelInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ModelInfo)
+	err := c.cc.Invoke(ctx, DetectionTrackingPipeline_ReloadModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *detectionTrackingPipelineClient) StreamFrames(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[FrameData, Ack], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DetectionTrackingPipeline_ServiceDesc.Streams[0], DetectionTrackingPipeline_StreamFrames_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FrameData, Ack]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DetectionTrackingPipeline_StreamFramesClient = grpc.BidiStreamingClient[FrameData, Ack]
+
 func (c *detectionTrackingPipelineClient) ReceiveDataFromServer(ctx context.Context, in *Data, opts ...grpc.CallOption) (*DataResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(DataResponse)
@@ -90,6 +171,35 @@ func (c *detectionTrackingPipelineClient) ReceiveDataFromServer(ctx context.Cont
 	return out, nil
 }
 
+func (c *detectionTrackingPipelineClient) GetTrackingState(ctx context.Context, in *TrackingStateRequest, opts ...grpc.CallOption) (*TrackingState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TrackingState)
+	err := c.cc.Invoke(ctx, DetectionTrackingPipeline_GetTrackingState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *detectionTrackingPipelineClient) SubscribeTracks(ctx context.Context, in *TrackingStateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TrackingResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DetectionTrackingPipeline_ServiceDesc.Streams[1], DetectionTrackingPipeline_SubscribeTracks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TrackingStateRequest, TrackingResult]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DetectionTrackingPipeline_SubscribeTracksClient = grpc.ServerStreamingClient[TrackingResult]
+
 func (c *detectionTrackingPipelineClient) CheckConnection(ctx context.Context, in *Data, opts ...grpc.CallOption) (*Ack, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Ack)
@@ -107,10 +217,31 @@ type DetectionTrackingPipelineServer interface {
 	// A simple RPC to send data to the server
 	// and receive an acknowledgment
 	SendDataToServer(context.Context, *Data) (*Ack, error)
+	// Registers a source or renews its lease, the response carries the
+	// assigned source id and the configuration used for the source
+	RegisterSource(context.Context, *RegisterSourceRequest) (*RegisterSourceResponse, error)
+	// Removes a source, the payload carries the source id returned by
+	// RegisterSource or the host:port sent to SendDataToServer
+	UnregisterSource(context.Context, *Data) (*Ack, error)
 	SendFrameToServer(context.Context, *FrameData) (*Ack, error)
 	SendDetectedFrameToServer(context.Context, *FrameData) (*Ack, error)
+	// Runs the detector on a frame and returns the detections to the
+	// caller, the frame is not forwarded to the tracker
+	DetectFrame(context.Context, *FrameData) (*DetectionResponse, error)
+	// Loads the model manifest of the detector again and swaps the models
+	// once the new ones are loaded, frames in flight finish on the old models
+	ReloadModel(context.Context, *Data) (*ModelInfo, error)
+	// A long-lived stream of frames, each frame is acknowledged with an Ack
+	// carrying its source and frame id so the sender can match them
+	StreamFrames(grpc.BidiStreamingServer[FrameData, Ack]) error
 	// A simple RPC to request data from the local storage
 	ReceiveDataFromServer(context.Context, *Data) (*DataResponse, error)
+	// Returns the active tracks of a source, or of all sources when
+	// no source id is given
+	GetTrackingState(context.Context, *TrackingStateRequest) (*TrackingState, error)
+	// Streams the tracking result of every processed frame of a source,
+	// or of all sources when no source id is given
+	SubscribeTracks(*TrackingStateRequest, grpc.ServerStreamingServer[TrackingResult]) error
 	// A simple RPC to send a ping to the service and receive a pong primarily for
 	// testing the connection and latency
 	CheckConnection(context.Context, *Data) (*Ack, error)
@@ -127,15 +258,36 @@ type UnimplementedDetectionTrackingPipelineServer struct{}
 func (UnimplementedDetectionTrackingPipelineServer) SendDataToServer(context.Context, *Data) (*Ack, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SendDataToServer not implemented")
 }
+func (UnimplementedDetectionTrackingPipelineServer) RegisterSource(context.Context, *RegisterSourceRequest) (*RegisterSourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterSource not implemented")
+}
+func (UnimplementedDetectionTrackingPipelineServer) UnregisterSource(context.Context, *Data) (*Ack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnregisterSource not implemented")
+}
 func (UnimplementedDetectionTrackingPipelineServer) SendFrameToServer(context.Context, *FrameData) (*Ack, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SendFrameToServer not implemented")
 }
 func (UnimplementedDetectionTrackingPipelineServer) SendDetectedFrameToServer(context.Context, *FrameData) (*Ack, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SendDetectedFrameToServer not implemented")
 }
+func (UnimplementedDetectionTrackingPipelineServer) DetectFrame(context.Context, *FrameData) (*DetectionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DetectFrame not implemented")
+}
+func (UnimplementedDetectionTrackingPipelineServer) ReloadModel(context.Context, *Data) (*ModelInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadModel not implemented")
+}
+func (UnimplementedDetectionTrackingPipelineServer) StreamFrames(grpc.BidiStreamingServer[FrameData, Ack]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamFrames not implemented")
+}
 func (UnimplementedDetectionTrackingPipelineServer) ReceiveDataFromServer(context.Context, *Data) (*DataResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReceiveDataFromServer not implemented")
 }
+func (UnimplementedDetectionTrackingPipelineServer) GetTrackingState(context.Context, *TrackingStateRequest) (*TrackingState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTrackingState not implemented")
+}
+func (UnimplementedDetectionTrackingPipelineServer) SubscribeTracks(*TrackingStateRequest, grpc.ServerStreamingServer[TrackingResult]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeTracks not implemented")
+}
 func (UnimplementedDetectionTrackingPipelineServer) CheckConnection(context.Context, *Data) (*Ack, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckConnection not implemented")
 }
@@ -179,6 +331,42 @@ func _DetectionTrackingPipeline_SendDataToServer_Handler(srv interface{}, ctx co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DetectionTrackingPipeline_RegisterSource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterSourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DetectionTrackingPipelineServer).RegisterSource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DetectionTrackingPipeline_RegisterSource_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DetectionTrackingPipelineServer).RegisterSource(ctx, req.(*RegisterSourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DetectionTrackingPipeline_UnregisterSource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Data)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DetectionTrackingPipelineServer).UnregisterSource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DetectionTrackingPipeline_UnregisterSource_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DetectionTrackingPipelineServer).UnregisterSource(ctx, req.(*Data))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _DetectionTrackingPipeline_SendFrameToServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(FrameData)
 	if err := dec(in); err != nil {
@@ -215,6 +403,49 @@ func _DetectionTrackingPipeline_SendDetectedFrameToServer_Handler(srv interface{
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DetectionTrackingPipeline_DetectFrame_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FrameData)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DetectionTrackingPipelineServer).DetectFrame(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DetectionTrackingPipeline_DetectFrame_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DetectionTrackingPipelineServer).DetectFrame(ctx, req.(*FrameData))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DetectionTrackingPipeline_ReloadModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Data)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DetectionTrackingPipelineServer).ReloadModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DetectionTrackingPipeline_ReloadModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DetectionTrackingPipelineServer).ReloadModel(ctx, req.(*Data))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DetectionTrackingPipeline_StreamFrames_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DetectionTrackingPipelineServer).StreamFrames(&grpc.GenericServerStream[FrameData, Ack]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DetectionTrackingPipeline_StreamFramesServer = grpc.BidiStreamingServer[FrameData, Ack]
+
 func _DetectionTrackingPipeline_ReceiveDataFromServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Data)
 	if err := dec(in); err != nil {
@@ -233,6 +464,35 @@ func _DetectionTrackingPipeline_ReceiveDataFromServer_Handler(srv interface{}, c
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DetectionTrackingPipeline_GetTrackingState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TrackingStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DetectionTrackingPipelineServer).GetTrackingState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DetectionTrackingPipeline_GetTrackingState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DetectionTrackingPipelineServer).GetTrackingState(ctx, req.(*TrackingStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DetectionTrackingPipeline_SubscribeTracks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TrackingStateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DetectionTrackingPipelineServer).SubscribeTracks(m, &grpc.GenericServerStream[TrackingStateRequest, TrackingResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DetectionTrackingPipeline_SubscribeTracksServer = grpc.ServerStreamingServer[TrackingResult]
+
 func _DetectionTrackingPipeline_CheckConnection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Data)
 	if err := dec(in); err != nil {
@@ -262,6 +522,14 @@ var DetectionTrackingPipeline_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SendDataToServer",
 			Handler:    _DetectionTrackingPipeline_SendDataToServer_Handler,
 		},
+		{
+			MethodName: "RegisterSource",
+			Handler:    _DetectionTrackingPipeline_RegisterSource_Handler,
+		},
+		{
+			MethodName: "UnregisterSource",
+			Handler:    _DetectionTrackingPipeline_UnregisterSource_Handler,
+		},
 		{
 			MethodName: "SendFrameToServer",
 			Handler:    _DetectionTrackingPipeline_SendFrameToServer_Handler,
@@ -270,15 +538,39 @@ var DetectionTrackingPipeline_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SendDetectedFrameToServer",
 			Handler:    _DetectionTrackingPipeline_SendDetectedFrameToServer_Handler,
 		},
+		{
+			MethodName: "DetectFrame",
+			Handler:    _DetectionTrackingPipeline_DetectFrame_Handler,
+		},
+		{
+			MethodName: "ReloadModel",
+			Handler:    _DetectionTrackingPipeline_ReloadModel_Handler,
+		},
 		{
 			MethodName: "ReceiveDataFromServer",
 			Handler:    _DetectionTrackingPipeline_ReceiveDataFromServer_Handler,
 		},
+		{
+			MethodName: "GetTrackingState",
+			Handler:    _DetectionTrackingPipeline_GetTrackingState_Handler,
+		},
 		{
 			MethodName: "CheckConnection",
 			Handler:    _DetectionTrackingPipeline_CheckConnection_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamFrames",
+			Handler:       _DetectionTrackingPipeline_StreamFrames_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SubscribeTracks",
+			Handler:       _DetectionTrackingPipeline_SubscribeTracks_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "detection_tracking_pipeline.proto",
 }
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"image"
 	"log"
 	"net"
 	"strconv"
@@ -65,6 +66,74 @@ func ParseBuckets(env string) []float64 {
 	return buckets
 }
 
+// ParseLabels parses a comma-separated list of key=value pairs into a map
+func ParseLabels(env string) map[string]string {
+	if env == "" {
+		return nil
+	}
+	labels := make(map[string]string)
+	for _, p := range strings.Split(env, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(p), "=")
+		if !found || key == "" {
+			fmt.Printf("Error parsing label '%s'\n", p)
+			continue
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+// ParseEndpoints parses a comma-separated list of host:port endpoints
+func ParseEndpoints(env string) ([]api.Service, error) {
+	if env == "" {
+		return nil, nil
+	}
+	var endpoints []api.Service
+	for _, p := range strings.Split(env, ",") {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint '%s': %v", p, err)
+		}
+		endpoints = append(endpoints, api.Service{Address: host, Port: port})
+	}
+	return endpoints, nil
+}
+
+// Services sending the detected frames to the tracker, both the detector and
+// the aggregator read the setting from DETECTION_FORWARDER
+const (
+	ForwarderDetector   = "detector"
+	ForwarderAggregator = "aggregator"
+)
+
+// ParseDetectionForwarder checks the service forwarding the detections, the
+// detector forwards them if it is empty
+func ParseDetectionForwarder(env string) (string, error) {
+	switch env {
+	case "":
+		return ForwarderDetector, nil
+	case ForwarderDetector, ForwarderAggregator:
+		return env, nil
+	default:
+		return "", fmt.Errorf("unknown detection forwarder '%s', expected '%s' or '%s'", env, ForwarderDetector, ForwarderAggregator)
+	}
+}
+
+// RectToBox converts an image rectangle into a bounding box message
+func RectToBox(r image.Rectangle) *pb.BoundingBox {
+	return &pb.BoundingBox{
+		XMin: int32(r.Min.X),
+		YMin: int32(r.Min.Y),
+		XMax: int32(r.Max.X),
+		YMax: int32(r.Max.Y),
+	}
+}
+
+// BoxToRect converts a bounding box message into an image rectangle
+func BoxToRect(b *pb.BoundingBox) image.Rectangle {
+	return image.Rect(int(b.GetXMin()), int(b.GetYMin()), int(b.GetXMax()), int(b.GetYMax()))
+}
+
 func GetOutboundIP() (string, error) {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
 	if err != nil {
@@ -111,7 +180,7 @@ func MonitorConnection1(targetSvc api.Service, clientRef *GrpcClient) {
 				conn.Close()
 			}
 			newConn, err := grpc.NewClient(
-				targetSvc.Address+":"+targetSvc.Port,
+				net.JoinHostPort(targetSvc.Address, targetSvc.Port),
 				grpc.WithTransportCredentials(insecure.NewCredentials()))
 			if err != nil {
 				log.Println("Failed to connect:", err)
@@ -146,7 +215,7 @@ func MonitorConnection(targetSvc api.Service, clientRef *atomic.Value) {
 				conn.Close()
 			}
 			newConn, err := grpc.NewClient(
-				targetSvc.Address+":"+targetSvc.Port,
+				net.JoinHostPort(targetSvc.Address, targetSvc.Port),
 				grpc.WithTransportCredentials(insecure.NewCredentials()))
 			if err != nil {
 				log.Println("Failed to connect:", err)
@@ -11,8 +11,10 @@ import (
 	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
 	api "github.com/etesami/detection-tracking-system/api"
+	"github.com/etesami/detection-tracking-system/pkg/codec"
 	metric "github.com/etesami/detection-tracking-system/pkg/metric"
 	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
 	utils "github.com/etesami/detection-tracking-system/pkg/utils"
@@ -54,24 +56,102 @@ func main() {
 	queueSize, _ := strconv.Atoi(os.Getenv("QUEUE_SIZE"))
 	maxTotalFrames, _ := strconv.Atoi(os.Getenv("MAX_TOTAL_FRAMES"))
 	detectionFrequency, _ := strconv.Atoi(os.Getenv("DETECTION_FREQUENCY"))
+	detectionFrequencyMin, _ := strconv.Atoi(os.Getenv("DETECTION_FREQUENCY_MIN"))
+	detectionFrequencyMax, _ := strconv.Atoi(os.Getenv("DETECTION_FREQUENCY_MAX"))
+	maxInFlight, _ := strconv.Atoi(os.Getenv("MAX_IN_FLIGHT_FRAMES"))
+	imageWidth, _ := strconv.Atoi(os.Getenv("IMAGE_WIDTH"))
+	imageHeight, _ := strconv.Atoi(os.Getenv("IMAGE_HEIGHT"))
+	motionThreshold, _ := strconv.ParseFloat(os.Getenv("MOTION_THRESHOLD"), 64)
+	motionIdleInterval, _ := strconv.Atoi(os.Getenv("MOTION_IDLE_INTERVAL"))
+	motionRegions, err := internal.ParseRegions(os.Getenv("MOTION_REGIONS"))
+	if err != nil {
+		log.Fatalf("Failed to parse motion regions: %v", err)
+	}
+	// The detector reads the same setting, so only one of them forwards
+	forwarder, err := utils.ParseDetectionForwarder(os.Getenv("DETECTION_FORWARDER"))
+	if err != nil {
+		log.Fatalf("Failed to parse detection forwarder: %v", err)
+	}
+	encoding, err := codec.ParseEncoding(os.Getenv("FRAME_ENCODING"))
+	if err != nil {
+		log.Fatalf("Failed to parse frame encoding: %v", err)
+	}
+	encodingQuality, _ := strconv.Atoi(os.Getenv("FRAME_ENCODING_QUALITY"))
+	reconnectMinBackoff, _ := strconv.Atoi(os.Getenv("RECONNECT_MIN_BACKOFF_MS"))
+	reconnectMaxBackoff, _ := strconv.Atoi(os.Getenv("RECONNECT_MAX_BACKOFF_MS"))
+	leaseDuration, _ := strconv.Atoi(os.Getenv("LEASE_DURATION"))
+	if leaseDuration <= 0 {
+		leaseDuration = 15 // Default to 15 seconds if not set
+	}
 
 	conf := &internal.Config{
-		QueueSize:          queueSize,
-		FrameRate:          float64(frameRate),
-		MaxTotalFrames:     maxTotalFrames,
-		DetectionFrequency: detectionFrequency,
+		QueueSize:             queueSize,
+		FrameRate:             float64(frameRate),
+		MaxTotalFrames:        maxTotalFrames,
+		DetectionFrequency:    detectionFrequency,
+		DetectionFrequencyMin: detectionFrequencyMin,
+		DetectionFrequencyMax: detectionFrequencyMax,
+		ImageWidth:            imageWidth,
+		ImageHeight:           imageHeight,
+		StreamPath:            os.Getenv("STREAM_PATH"),
+		MotionGating:          os.Getenv("MOTION_GATING") == "true",
+		MotionThreshold:       motionThreshold,
+		MotionRegions:         motionRegions,
+		MotionIdleInterval:    motionIdleInterval,
+		Encoding:              encoding,
+		EncodingQuality:       encodingQuality,
+		ForwardDetections:     forwarder == utils.ForwarderAggregator,
+
+		ReconnectMinBackoff: time.Duration(reconnectMinBackoff) * time.Millisecond,
+		ReconnectMaxBackoff: time.Duration(reconnectMaxBackoff) * time.Millisecond,
 	}
 
 	s := &internal.Server{
-		Clients:      sync.Map{},
-		RegisterCh:   make(chan *api.Service, 100),
-		DtClient:     utils.GrpcClient{},
-		TrClient:     utils.GrpcClient{},
-		GlovalConfig: conf,
+		Clients:       sync.Map{},
+		RegisterCh:    make(chan *api.Service, 100),
+		Metric:        m,
+		GlovalConfig:  conf,
+		LeaseDuration: time.Duration(leaseDuration) * time.Second,
+	}
+
+	// Setup the remote services (detection and tracking) and start the connections as clients
+	// when we send data to the remote services when we have to. Several replicas of each
+	// service can be given as a list of endpoints, otherwise the single host and port are used
+	dtEndpoints, err := remoteEndpoints("REMOTE_DETECTION_ENDPOINTS", "REMOTE_DETECTION_HOST", "REMOTE_DETECTION_PORT")
+	if err != nil {
+		log.Fatalf("Failed to parse detector endpoints: %v", err)
+	}
+	dtStreams, _ := frameStreams("detector", dtEndpoints, maxInFlight, m)
+	s.DtStream, err = internal.NewBalancer(os.Getenv("DETECTION_BALANCER"), dtStreams)
+	if err != nil {
+		log.Fatalf("Failed to create detector balancer: %v", err)
+	}
+
+	trEndpoints, err := remoteEndpoints("REMOTE_TRACKER_ENDPOINTS", "REMOTE_TRACKER_HOST", "REMOTE_TRACKER_PORT")
+	if err != nil {
+		log.Fatalf("Failed to parse tracker endpoints: %v", err)
+	}
+	// The detector only knows a single tracker, the detections of a source
+	// reach the tracker owning it only if the aggregator forwards them
+	if len(trEndpoints) > 1 && !conf.ForwardDetections {
+		log.Fatalf("[%d] trackers are configured, DETECTION_FORWARDER must be [%s]", len(trEndpoints), utils.ForwarderAggregator)
+	}
+	// Trackers keep state per source, so each source is pinned to one tracker
+	trStreams, trKeys := frameStreams("tracker", trEndpoints, maxInFlight, m)
+	ringReplicas, _ := strconv.Atoi(os.Getenv("TRACKER_RING_REPLICAS"))
+	s.TrStream, err = internal.NewHashRing(trStreams, trKeys, ringReplicas)
+	if err != nil {
+		log.Fatalf("Failed to create tracker ring: %v", err)
 	}
+
 	grpcServer := grpc.NewServer()
 	pb.RegisterDetectionTrackingPipelineServer(grpcServer, s)
 
+	// Clients renew their lease with every ping, the ones that stop
+	// pinging are removed
+	stopExpiry := make(chan struct{})
+	go s.ExpireClients(stopExpiry, time.Second)
+
 	go func() {
 		log.Printf("starting gRPC server on port %s:%s\n", localSvc.Address, localSvc.Port)
 		if err := grpcServer.Serve(listener); err != nil {
@@ -79,31 +159,6 @@ func main() {
 		}
 	}()
 
-	// Setup the remote service (detection and tracking) and start the connection as client
-	// when we send data to the remote services when we have to
-	REMOTE_DETECTOR_HOST := os.Getenv("REMOTE_DETECTION_HOST")
-	REMOTE_DETECTOR_PORT := os.Getenv("REMOTE_DETECTION_PORT")
-	if REMOTE_DETECTOR_HOST == "" || REMOTE_DETECTOR_PORT == "" {
-		panic("REMOTE_DETECTOR_HOST or REMOTE_DETECTOR_PORT environment variable is not set")
-	}
-
-	tarDtSvc := api.Service{
-		Address: REMOTE_DETECTOR_HOST,
-		Port:    REMOTE_DETECTOR_PORT,
-	}
-	go utils.MonitorConnection1(tarDtSvc, &s.DtClient)
-
-	REMOTE_TRACKER_HOST := os.Getenv("REMOTE_TRACKER_HOST")
-	REMOTE_TRACKER_PORT := os.Getenv("REMOTE_TRACKER_PORT")
-	if REMOTE_TRACKER_HOST == "" || REMOTE_TRACKER_PORT == "" {
-		panic("REMOTE_TRACKER_HOST or REMOTE_TRACKER_PORT environment variable is not set")
-	}
-	targetTrackingSvc := api.Service{
-		Address: REMOTE_TRACKER_HOST,
-		Port:    REMOTE_TRACKER_PORT,
-	}
-	go utils.MonitorConnection1(targetTrackingSvc, &s.TrClient)
-
 	metricAddr := os.Getenv("METRIC_ADDR")
 	metricPort := os.Getenv("METRIC_PORT")
 	mux := http.NewServeMux()
@@ -130,9 +185,10 @@ func main() {
 
 	<-sigChan // Wait for signal
 	log.Printf("Received shutdown signal\n")
-	for _, vi := range s.VideoInputs {
-		vi.Signal.Close()
-	}
+	close(stopExpiry)
+	s.CloseClients()
+	s.DtStream.Close()
+	s.TrStream.Close()
 	// cancel()                  // Cancel the context
 	grpcServer.GracefulStop() // Stop the gRPC server gracefully
 	if err := server.Shutdown(context.Background()); err != nil {
@@ -140,3 +196,38 @@ func main() {
 	}
 	log.Printf("Server shut down gracefully\n")
 }
+
+// remoteEndpoints returns the endpoints of a remote service from the list in
+// listEnv, or from the single host and port if the list is not set
+func remoteEndpoints(listEnv, hostEnv, portEnv string) ([]api.Service, error) {
+	endpoints, err := utils.ParseEndpoints(os.Getenv(listEnv))
+	if err != nil || len(endpoints) > 0 {
+		return endpoints, err
+	}
+	host := os.Getenv(hostEnv)
+	port := os.Getenv(portEnv)
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("%s or %s/%s environment variables are not set", listEnv, hostEnv, portEnv)
+	}
+	return []api.Service{{Address: host, Port: port}}, nil
+}
+
+// frameStreams creates a frame stream to every endpoint and starts monitoring
+// its connection, it returns the streams and the addresses of the endpoints
+func frameStreams(name string, endpoints []api.Service, maxInFlight int, m *metric.Metric) ([]*internal.FrameStream, []string) {
+	streams := make([]*internal.FrameStream, 0, len(endpoints))
+	addrs := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		addr := net.JoinHostPort(ep.Address, ep.Port)
+		// Streams of several replicas are told apart in logs and metrics
+		streamName := name
+		if len(endpoints) > 1 {
+			streamName = name + "/" + addr
+		}
+		clientRef := &utils.GrpcClient{}
+		go utils.MonitorConnection1(ep, clientRef)
+		streams = append(streams, internal.NewFrameStream(streamName, clientRef, maxInFlight, m))
+		addrs = append(addrs, addr)
+	}
+	return streams, addrs
+}
@@ -7,6 +7,14 @@ export REMOTE_DETECTION_PORT=5003
 export REMOTE_TRACKER_HOST=localhost
 export REMOTE_TRACKER_PORT=5004
 
+# Comma-separated host:port lists of detector and tracker replicas, they
+# take precedence over the single host and port above. Several trackers need
+# DETECTION_FORWARDER="aggregator".
+export REMOTE_DETECTION_ENDPOINTS=""
+export REMOTE_TRACKER_ENDPOINTS=""
+export DETECTION_BALANCER="round-robin"
+export TRACKER_RING_REPLICAS=100
+
 export SVC_INGST_ADDR=localhost
 export SVC_INGST_PORT=5002
 
@@ -17,4 +25,22 @@ export METRIC_PORT=8002
 export FRAME_RATE=5
 export QUEUE_SIZE=180
 export MAX_TOTAL_FRAMES=41
-export DETECTION_FREQUENCY=5
\ No newline at end of file
+export DETECTION_FREQUENCY=5
+export MAX_IN_FLIGHT_FRAMES=8
+export LEASE_DURATION=15
+export RECONNECT_MIN_BACKOFF_MS=500
+export RECONNECT_MAX_BACKOFF_MS=30000
+export IMAGE_WIDTH=640
+export IMAGE_HEIGHT=360
+export STREAM_PATH="/stream"
+export DETECTION_FREQUENCY_MIN=2
+export DETECTION_FREQUENCY_MAX=15
+export MOTION_GATING="false"
+export MOTION_THRESHOLD=0.005
+export MOTION_IDLE_INTERVAL=10
+export MOTION_REGIONS=""
+export FRAME_ENCODING="jpeg"
+export FRAME_ENCODING_QUALITY=90
+# Service sending the detections to the tracker, "detector" or "aggregator",
+# it must be set the same in the detector
+export DETECTION_FORWARDER="detector"
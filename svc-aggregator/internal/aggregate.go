@@ -2,86 +2,193 @@ package internal
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
+	"net"
+	"net/url"
 	"sync"
 	"time"
 
 	api "github.com/etesami/detection-tracking-system/api"
+	metric "github.com/etesami/detection-tracking-system/pkg/metric"
 	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
-	"github.com/etesami/detection-tracking-system/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Server struct {
 	pb.UnimplementedDetectionTrackingPipelineServer
 
-	Clients     sync.Map // map[string]*Service
-	VideoInputs []*VideoInput
-	DtClient    utils.GrpcClient
-	TrClient    utils.GrpcClient
+	Clients sync.Map // map[string]*Source
+	// Long-lived frame streams to the detector and tracker replicas, frames
+	// are balanced over the detectors and sharded by source over the trackers
+	DtStream FrameSender
+	TrStream FrameSender
+	Metric   *metric.Metric
 
 	// Channel for a new client
 	RegisterCh   chan *api.Service
 	GlovalConfig *Config
+	// How long a registration stays valid without being renewed
+	LeaseDuration time.Duration
 }
 
-// AddClient adds a new client connection data to the server
-// and starts a new video input stream for that client
-func (s *Server) AddClient(address, port string) {
-	c := api.Service{
-		Address: address,
-		Port:    port,
-	}
-	// Check if the client already exists
-	_, loaded := s.Clients.LoadOrStore(fmt.Sprintf("%s:%s", address, port), &c)
-
-	if !loaded {
-		log.Printf("Added new client: %s:%s\n", address, port)
-		cfg := Config{
-			VideoSource:        fmt.Sprintf("rtsp://%s:%s/stream", address, port),
-			QueueSize:          s.GlovalConfig.QueueSize,
-			FrameRate:          float64(s.GlovalConfig.FrameRate),
-			MaxTotalFrames:     s.GlovalConfig.MaxTotalFrames,
-			DetectionFrequency: s.GlovalConfig.DetectionFrequency,
-			ImageWidth:         640,
-			ImageHeight:        360,
-		}
-		if vi, err := NewVideoInput(&cfg, &s.DtClient, &s.TrClient); err != nil {
-			log.Printf("Error creating video input: %v\n", err)
-			return
-		} else {
-			s.VideoInputs = append(s.VideoInputs, vi)
+// Source is a registered data source and the video input reading from it
+type Source struct {
+	// Key of the source in Clients
+	Id      string
+	Service *api.Service
+	Config  Config
+	Name    string
+	Codec   string
+	Labels  map[string]string
+
+	mu          sync.Mutex
+	videoInput  *VideoInput
+	leaseExpiry time.Time
+}
+
+// VideoInput returns the video input of the source, it is nil while the
+// video input is being created
+func (src *Source) VideoInput() *VideoInput {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.videoInput
+}
+
+// renew extends the lease of the source
+func (src *Source) renew(d time.Duration) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.leaseExpiry = time.Now().Add(d)
+}
+
+// expired reports whether the lease of the source has run out
+func (src *Source) expired(now time.Time) bool {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return now.After(src.leaseExpiry)
+}
+
+// AddClient registers the source under its id and starts a new video input
+// stream for it. For a known source the lease is renewed instead and the
+// known source is returned, its configuration is not changed.
+func (s *Server) AddClient(newSrc *Source) (*Source, error) {
+	key := newSrc.Id
+	if v, found := s.Clients.Load(key); found {
+		src := v.(*Source)
+		if vi := src.VideoInput(); vi == nil || !vi.Stopped() {
+			src.renew(s.LeaseDuration)
+			return src, nil
 		}
+		// The video input stopped on its own, e.g. after too many empty
+		// frames, start it again for the renewing client
+		log.Printf("Video input of client [%s] has stopped, restarting it\n", key)
+		s.Clients.CompareAndDelete(key, src)
+	}
+
+	newSrc.renew(s.LeaseDuration)
+	// Reserve the key so concurrent registrations of the same client do
+	// not open the stream twice
+	if v, loaded := s.Clients.LoadOrStore(key, newSrc); loaded {
+		src := v.(*Source)
+		src.renew(s.LeaseDuration)
+		return src, nil
+	}
+	cfg := &newSrc.Config
+	log.Printf("Added new client: [%s], source: [%s] [%dx%d]\n", key, cfg.SourceId, cfg.ImageWidth, cfg.ImageHeight)
 
-		log.Printf("Video input created for client: %s:%s\n", address, port)
+	vi, err := NewVideoInput(cfg, s.DtStream, s.TrStream, s.Metric)
+	if err != nil {
+		s.Clients.Delete(key)
+		return nil, fmt.Errorf("error creating video input: %v", err)
 	}
+	newSrc.mu.Lock()
+	newSrc.videoInput = vi
+	newSrc.mu.Unlock()
+
+	log.Printf("Video input created for client: [%s]\n", key)
+	return newSrc, nil
 }
 
 // RemoveClient removes a client connection data from the server
-func (s *Server) RemoveClient(address, port string) {
-	if _, exists := s.Clients.Load(fmt.Sprintf("%s:%s", address, port)); exists {
-		s.Clients.Delete(fmt.Sprintf("%s:%s", address, port))
-		log.Printf("Removed client: %s:%s\n", address, port)
-	} else {
-		log.Printf("Client not found: %s:%s\n", address, port)
+// and stops its video input
+func (s *Server) RemoveClient(key string) bool {
+	v, exists := s.Clients.LoadAndDelete(key)
+	if !exists {
+		log.Printf("Client not found: [%s]\n", key)
+		return false
+	}
+	if vi := v.(*Source).VideoInput(); vi != nil {
+		vi.Signal.Close()
 	}
+	log.Printf("Removed client: [%s]\n", key)
+	return true
 }
 
-// SendDataToServer handles incoming data from clients
+// ExpireClients periodically removes the clients whose lease has expired
+// until done is closed
+func (s *Server) ExpireClients(done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			s.Clients.Range(func(key, value any) bool {
+				src := value.(*Source)
+				if src.VideoInput() != nil && src.expired(now) {
+					log.Printf("Lease of client [%s] expired", key)
+					s.RemoveClient(src.Id)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// CloseClients stops the video inputs of all clients
+func (s *Server) CloseClients() {
+	s.Clients.Range(func(key, value any) bool {
+		if vi := value.(*Source).VideoInput(); vi != nil {
+			vi.Signal.Close()
+		}
+		return true
+	})
+}
+
+// SendDataToServer handles incoming data from clients, a client registers
+// and renews its lease by sending its host:port
 func (s *Server) SendDataToServer(ctx context.Context, recData *pb.Data) (*pb.Ack, error) {
 	recTime := time.Now().Format(time.RFC3339Nano)
 	log.Printf("Received at [%s]: [%d] Bytes\n", recTime, len(recData.Payload))
 
-	// Expect the message to be in the format of host:ip
-	parts := strings.Split(recData.Payload, ":")
-	if len(parts) != 2 {
+	// Expect the message to be in the format of host:port
+	host, port, err := net.SplitHostPort(recData.Payload)
+	if err != nil {
 		log.Printf("Invalid payload format: %s", recData.Payload)
 		return nil, fmt.Errorf("invalid payload format")
-	} else {
-		// add connection information to the list of clients if not already present
-		s.AddClient(parts[0], parts[1])
+	}
+	key := net.JoinHostPort(host, port)
+	u := &url.URL{
+		Scheme: "rtsp",
+		Host:   key,
+		Path:   recData.GetProfile().GetStreamPath(),
+	}
+
+	// add connection information to the list of clients if not already present
+	src := &Source{
+		Id: key,
+		Service: &api.Service{
+			Address: host,
+			Port:    port,
+		},
+		Config: s.sourceConfig(u, recData.GetProfile()),
+	}
+	if _, err := s.AddClient(src); err != nil {
+		log.Printf("Error adding client [%s]: %v\n", key, err)
+		return nil, status.Errorf(codes.InvalidArgument, "error adding client [%s]: %v", key, err)
 	}
 
 	ack := &pb.Ack{
@@ -94,33 +201,78 @@ func (s *Server) SendDataToServer(ctx context.Context, recData *pb.Data) (*pb.Ac
 	return ack, nil
 }
 
-// SendFrame sends a frame to the detector/tracker service
-func SendFrame(f api.FrameMetadata, frameByte []byte, clientRef *utils.GrpcClient, dstSvcName string) error {
-	client := clientRef.Load()
-	if client == nil {
-		return fmt.Errorf("client is not initialized")
+// RegisterSource registers a source described by its stream URL or renews
+// its lease, and returns the id and the configuration of the source
+func (s *Server) RegisterSource(ctx context.Context, req *pb.RegisterSourceRequest) (*pb.RegisterSourceResponse, error) {
+	recTime := time.Now().Format(time.RFC3339Nano)
+
+	u, err := url.Parse(req.StreamUrl)
+	if err != nil || u.Scheme == "" || u.Hostname() == "" {
+		log.Printf("Invalid stream url: %s", req.StreamUrl)
+		return nil, status.Errorf(codes.InvalidArgument, "invalid stream url: %s", req.StreamUrl)
+	}
+	switch req.Transport {
+	case "", "tcp", "udp":
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported transport: %s", req.Transport)
 	}
 
-	metaByte, err := json.Marshal(f)
+	cfg := s.sourceConfig(u, req.Profile)
+	cfg.Transport = req.Transport
+	// The name of the source is its id, otherwise the stream url is used
+	if req.Name != "" {
+		cfg.SourceId = req.Name
+	}
+	// A conflicting registration must not renew the lease of the source
+	// registered under the same id
+	if v, found := s.Clients.Load(cfg.SourceId); found && v.(*Source).Config.VideoSource != cfg.VideoSource {
+		return nil, status.Errorf(codes.AlreadyExists, "source [%s] is registered with another stream", cfg.SourceId)
+	}
+	src, err := s.AddClient(&Source{
+		Id: cfg.SourceId,
+		Service: &api.Service{
+			Address: u.Hostname(),
+			Port:    u.Port(),
+		},
+		Config: cfg,
+		Name:   req.Name,
+		Codec:  req.Codec,
+		Labels: req.Labels,
+	})
 	if err != nil {
-		return fmt.Errorf("error marshalling metadata: %v", err)
+		return nil, status.Errorf(codes.Unavailable, "error registering source [%s]: %v", cfg.SourceId, err)
 	}
-
-	d := &pb.FrameData{
-		FrameData:     frameByte,
-		Metadata:      string(metaByte),
-		SentTimestamp: time.Now().Format(time.RFC3339Nano),
+	// a concurrent registration of another stream may have won the id
+	if src.Config.VideoSource != cfg.VideoSource {
+		return nil, status.Errorf(codes.AlreadyExists, "source [%s] is registered with another stream", cfg.SourceId)
 	}
 
-	pong, err := client.SendFrameToServer(context.Background(), d)
-	if err != nil {
-		return fmt.Errorf("error sending frame to server: %v", err)
+	return &pb.RegisterSourceResponse{
+		Status:                "ok",
+		SourceId:              src.Id,
+		Config:                src.Config.Profile(),
+		LeaseSeconds:          int64(s.LeaseDuration / time.Second),
+		OriginalSentTimestamp: req.SentTimestamp,
+		ReceivedTimestamp:     recTime,
+		AckSentTimestamp:      time.Now().Format(time.RFC3339Nano),
+	}, nil
+}
+
+// UnregisterSource removes a client on its request, the payload is the
+// source id or the host:port the client registered with
+func (s *Server) UnregisterSource(ctx context.Context, recData *pb.Data) (*pb.Ack, error) {
+	recTime := time.Now().Format(time.RFC3339Nano)
+
+	if !s.RemoveClient(recData.Payload) {
+		return nil, status.Errorf(codes.NotFound, "source [%s] is not registered", recData.Payload)
 	}
 
-	rtt, err := utils.CalculateRtt(d.SentTimestamp, pong.ReceivedTimestamp, pong.AckSentTimestamp, time.Now().Format(time.RFC3339Nano))
-	if err != nil {
-		return fmt.Errorf("error calculating RTT: %v", err)
+	ack := &pb.Ack{
+		Status:                "ok",
+		OriginalSentTimestamp: recData.SentTimestamp,
+		ReceivedTimestamp:     recTime,
+		AckSentTimestamp:      time.Now().Format(time.RFC3339Nano),
 	}
-	log.Printf("Sent frame [%d], [%s] response: [%s], RTT [%.2f] ms\n", f.FrameId, dstSvcName, pong.Status, float64(rtt)/1000.0)
-	return nil
+
+	return ack, nil
 }
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+)
+
+// FrameSender delivers frames to one or more replicas of a downstream
+// service, a single FrameStream is a FrameSender with one replica
+type FrameSender interface {
+	Send(done <-chan struct{}, d *pb.FrameData) error
+	Listen(sourceId string, fn AckListener)
+	Close()
+}
+
+// Balancing policies of the detector replicas
+const (
+	RoundRobin       = "round-robin"
+	LeastOutstanding = "least-outstanding"
+)
+
+// defaultRingReplicas is the number of points of every replica on the ring
+const defaultRingReplicas = 100
+
+// streamSet is the set of frame streams to the replicas of a service
+type streamSet []*FrameStream
+
+// Listen registers the listener on every replica, acks of a source may come
+// from any of them
+func (ss streamSet) Listen(sourceId string, fn AckListener) {
+	for _, fs := range ss {
+		fs.Listen(sourceId, fn)
+	}
+}
+
+// Close closes the streams to all replicas
+func (ss streamSet) Close() {
+	for _, fs := range ss {
+		fs.Close()
+	}
+}
+
+// Balancer spreads the frames over the replicas of a service regardless of
+// their source, replicas that are not connected are skipped
+type Balancer struct {
+	streamSet
+	policy string
+	next   atomic.Uint64
+}
+
+// NewBalancer creates a balancer over the streams with the given policy,
+// round-robin is used if the policy is empty
+func NewBalancer(policy string, streams []*FrameStream) (*Balancer, error) {
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("no streams to balance")
+	}
+	switch policy {
+	case "":
+		policy = RoundRobin
+	case RoundRobin, LeastOutstanding:
+	default:
+		return nil, fmt.Errorf("unknown balancing policy: %s", policy)
+	}
+	return &Balancer{streamSet: streams, policy: policy}, nil
+}
+
+// Send sends the frame to the replica picked by the policy
+func (b *Balancer) Send(done <-chan struct{}, d *pb.FrameData) error {
+	return b.pick().Send(done, d)
+}
+
+// pick returns the replica for the next frame. If no replica is connected the
+// next one in turn is returned and the send reports the error.
+func (b *Balancer) pick() *FrameStream {
+	n := uint64(len(b.streamSet))
+	start := b.next.Add(1)
+	var picked *FrameStream
+	for i := uint64(0); i < n; i++ {
+		fs := b.streamSet[(start+i)%n]
+		if !fs.Ready() {
+			continue
+		}
+		if b.policy == RoundRobin {
+			return fs
+		}
+		// Ties go to the first replica after the last pick so equally loaded
+		// replicas still take turns
+		if picked == nil || fs.InFlight() < picked.InFlight() {
+			picked = fs
+		}
+	}
+	if picked == nil {
+		return b.streamSet[start%n]
+	}
+	return picked
+}
+
+type ringPoint struct {
+	hash   uint32
+	stream *FrameStream
+}
+
+// HashRing shards the sources over the replicas of a service with consistent
+// hashing, so the frames of a source always go to the same replica. Adding or
+// removing a replica only moves the sources of the ring segments it owns. While
+// the owner of a source is not connected, the source goes to the next replica
+// on the ring.
+type HashRing struct {
+	streamSet
+	points []ringPoint
+}
+
+// NewHashRing places every stream on the ring under its key, usually the
+// address of the replica, with the given number of virtual nodes
+func NewHashRing(streams []*FrameStream, keys []string, replicas int) (*HashRing, error) {
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("no streams to shard")
+	}
+	if len(keys) != len(streams) {
+		return nil, fmt.Errorf("got [%d] keys for [%d] streams", len(keys), len(streams))
+	}
+	if replicas <= 0 {
+		replicas = defaultRingReplicas
+	}
+	r := &HashRing{streamSet: streams}
+	for i, fs := range streams {
+		for v := 0; v < replicas; v++ {
+			r.points = append(r.points, ringPoint{
+				hash:   hashKey(keys[i] + "#" + strconv.Itoa(v)),
+				stream: fs,
+			})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return r, nil
+}
+
+// hashKey hashes the key with FNV-1a followed by the murmur3 finalizer, FNV
+// alone spreads similar keys such as "cam-1" and "cam-2" poorly over the ring
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	x := h.Sum32()
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
+
+// Send sends the frame to the replica owning its source
+func (r *HashRing) Send(done <-chan struct{}, d *pb.FrameData) error {
+	return r.Owner(d.Metadata.SourceId).Send(done, d)
+}
+
+// Owner returns the first connected replica clockwise from the hash of the
+// source, or the owner itself if no replica is connected
+func (r *HashRing) Owner(sourceId string) *FrameStream {
+	h := hashKey(sourceId)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	for i := 0; i < len(r.points); i++ {
+		p := r.points[(start+i)%len(r.points)]
+		if p.stream.Ready() {
+			return p.stream
+		}
+	}
+	return r.points[start%len(r.points)].stream
+}
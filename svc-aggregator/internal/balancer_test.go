@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"github.com/etesami/detection-tracking-system/pkg/utils"
+)
+
+// testStreams creates n frame streams, the ones listed in ready are connected
+// and inFlight[i] frames are outstanding on stream i
+func testStreams(n int, ready []int, inFlight []int) []*FrameStream {
+	streams := make([]*FrameStream, n)
+	for i := range streams {
+		streams[i] = NewFrameStream(fmt.Sprintf("replica-%d", i), &utils.GrpcClient{}, 16, nil)
+	}
+	for _, i := range ready {
+		streams[i].clientRef.Store(pb.NewDetectionTrackingPipelineClient(nil))
+	}
+	for i, k := range inFlight {
+		for j := 0; j < k; j++ {
+			streams[i].window <- struct{}{}
+		}
+	}
+	return streams
+}
+
+func indexOf(streams []*FrameStream, fs *FrameStream) int {
+	for i, s := range streams {
+		if s == fs {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestBalancerPick(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		ready    []int
+		inFlight []int
+		// replicas picked by consecutive calls
+		want []int
+	}{
+		{"round-robin cycles", RoundRobin, []int{0, 1, 2}, nil, []int{1, 2, 0, 1, 2, 0}},
+		{"empty policy is round-robin", "", []int{0, 1, 2}, nil, []int{1, 2, 0}},
+		{"round-robin skips disconnected", RoundRobin, []int{0, 2}, nil, []int{2, 2, 0, 2, 2, 0}},
+		{"least-outstanding", LeastOutstanding, []int{0, 1, 2}, []int{3, 1, 2}, []int{1, 1, 1}},
+		{"least-outstanding skips disconnected", LeastOutstanding, []int{0, 2}, []int{3, 0, 2}, []int{2, 2}},
+		{"least-outstanding ties take turns", LeastOutstanding, []int{0, 1, 2}, []int{1, 1, 1}, []int{1, 2, 0}},
+		{"none connected", RoundRobin, nil, nil, []int{1, 2, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := testStreams(3, tt.ready, tt.inFlight)
+			b, err := NewBalancer(tt.policy, streams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range tt.want {
+				if got := indexOf(streams, b.pick()); got != want {
+					t.Fatalf("pick %d: got replica %d, want %d", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewBalancerErrors(t *testing.T) {
+	if _, err := NewBalancer(RoundRobin, nil); err == nil {
+		t.Error("expected an error without streams")
+	}
+	if _, err := NewBalancer("random", testStreams(1, nil, nil)); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func ringKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("tracker-%d:5004", i)
+	}
+	return keys
+}
+
+func TestHashRingOwner(t *testing.T) {
+	const sources = 3000
+	streams := testStreams(3, []int{0, 1, 2}, nil)
+	ring, err := NewHashRing(streams, ringKeys(3), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owners := make(map[string]int, sources)
+	counts := make([]int, len(streams))
+	for i := 0; i < sources; i++ {
+		src := fmt.Sprintf("cam-%d", i)
+		owner := indexOf(streams, ring.Owner(src))
+		if again := indexOf(streams, ring.Owner(src)); again != owner {
+			t.Fatalf("owner of [%s] changed from %d to %d", src, owner, again)
+		}
+		owners[src] = owner
+		counts[owner]++
+	}
+	for i, c := range counts {
+		// each replica should own roughly a third of the sources
+		if c < sources/5 || c > sources/2 {
+			t.Errorf("replica %d owns %d of %d sources", i, c, sources)
+		}
+	}
+
+	// A new replica only takes sources over, the others keep their owner
+	grown := append(streams, testStreams(1, []int{0}, nil)...)
+	bigger, err := NewHashRing(grown, ringKeys(4), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := 0
+	for src, owner := range owners {
+		switch now := indexOf(grown, bigger.Owner(src)); now {
+		case owner:
+		case 3:
+			moved++
+		default:
+			t.Fatalf("[%s] moved from replica %d to %d", src, owner, now)
+		}
+	}
+	if moved < sources/8 || moved > sources/2 {
+		t.Errorf("%d of %d sources moved to the new replica", moved, sources)
+	}
+}
+
+func TestHashRingFailover(t *testing.T) {
+	tests := []struct {
+		name  string
+		ready []int
+	}{
+		{"all connected", []int{0, 1, 2}},
+		{"owner disconnected", nil},
+		{"one connected", []int{1}},
+		{"none connected", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := testStreams(3, []int{0, 1, 2}, nil)
+			ring, _ := NewHashRing(all, ringKeys(3), 0)
+			owner := indexOf(all, ring.Owner("cam-1"))
+
+			ready := tt.ready
+			if ready == nil {
+				// every replica but the owner
+				for i := range all {
+					if i != owner {
+						ready = append(ready, i)
+					}
+				}
+			}
+			streams := testStreams(3, ready, nil)
+			ring, _ = NewHashRing(streams, ringKeys(3), 0)
+			got := indexOf(streams, ring.Owner("cam-1"))
+
+			switch {
+			case len(ready) == 0:
+				if got != owner {
+					t.Errorf("got replica %d, want the owner %d when none is connected", got, owner)
+				}
+			case !streams[got].Ready():
+				t.Errorf("got disconnected replica %d", got)
+			case streams[owner].Ready() && got != owner:
+				t.Errorf("got replica %d, want the connected owner %d", got, owner)
+			}
+		})
+	}
+}
+
+func TestNewHashRingErrors(t *testing.T) {
+	if _, err := NewHashRing(nil, nil, 0); err == nil {
+		t.Error("expected an error without streams")
+	}
+	if _, err := NewHashRing(testStreams(2, nil, nil), ringKeys(1), 0); err == nil {
+		t.Error("expected an error for missing keys")
+	}
+}
@@ -4,15 +4,22 @@ import (
 	"fmt"
 	"image"
 	"log"
+	"os"
 	"sync"
 	"time"
 
-	api "github.com/etesami/detection-tracking-system/api"
-	"github.com/etesami/detection-tracking-system/pkg/utils"
+	"github.com/etesami/detection-tracking-system/pkg/codec"
+	metric "github.com/etesami/detection-tracking-system/pkg/metric"
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
 
 	"gocv.io/x/gocv"
 )
 
+const ffmpegOptionsEnv = "OPENCV_FFMPEG_CAPTURE_OPTIONS"
+
+// detectorBusy is the ack status of a frame the overloaded detector rejected
+const detectorBusy = "busy"
+
 type signal struct {
 	Done chan struct{}
 	once sync.Once
@@ -25,26 +32,57 @@ func (s *signal) Close() {
 }
 
 type frameData struct {
-	metadata api.FrameMetadata
+	metadata *pb.FrameMetadata
 	frame    gocv.Mat
 }
 
 // Config holds the configuration parameters
 type Config struct {
-	VideoSource        string
+	// URL of the stream, it may contain credentials
+	VideoSource string
+	// Identifies the source in frames, logs and metrics
+	SourceId   string
+	StreamPath string
+	// RTSP transport, "tcp" or "udp", empty for the backend default
+	Transport          string
 	QueueSize          int
 	FrameRate          float64
 	MaxTotalFrames     int
 	DetectionFrequency int
-	ImageWidth         int
-	ImageHeight        int
+	// Bounds of the adaptive detection interval, the interval is fixed to
+	// DetectionFrequency when they are not set
+	DetectionFrequencyMin int
+	DetectionFrequencyMax int
+	// Motion gating suppresses frames without motion, one in every
+	// MotionIdleInterval idle frames is still sent, 0 suppresses them all
+	MotionGating       bool
+	MotionThreshold    float64
+	MotionRegions      []image.Rectangle
+	MotionIdleInterval int
+	// Encoding of the frames sent downstream, quality applies to JPEG and WebP
+	Encoding        pb.FrameEncoding
+	EncodingQuality int
+	// Size the frames are resized to, unless tiling is enabled
+	ImageWidth  int
+	ImageHeight int
+	// Whether the aggregator sends the detections returned by the detector
+	// to the tracker, otherwise the detector forwards them itself. Frames the
+	// detector did not forward are sent by the aggregator either way.
+	ForwardDetections bool
+	// Sliced inference settings sent to the detector with every frame, the
+	// detector default is used if nil. The frames are sent at the native
+	// resolution of the source when it is enabled, so they span several tiles.
+	Tiling *pb.TilingConfig
+	// Bounds of the exponential backoff between reconnection attempts
+	ReconnectMinBackoff time.Duration
+	ReconnectMaxBackoff time.Duration
 }
 
 // VideoInput manages video ingestion and processing
 type VideoInput struct {
 	config          *Config
-	grpcDtClientRef *utils.GrpcClient
-	grpcTrClientRef *utils.GrpcClient
+	dtStream        FrameSender
+	trStream        FrameSender
 	queue           chan frameData // Channel for frames
 	Signal          signal
 	frameCount      int
@@ -52,31 +90,86 @@ type VideoInput struct {
 	frameSkipped    int
 	capture         *gocv.VideoCapture
 	wg              sync.WaitGroup // WaitGroup to wait for goroutines to finish
+	metric          *metric.Metric
+	scheduler       *DetectionScheduler
+	motion          *MotionDetector
+	frameSuppressed int
+	// Frame ids continue from frameOffset after a reconnection, since the
+	// position of a reopened capture starts from zero again
+	frameOffset int64
+	lastFrameId int64
+	reconnects  int
+	// Id of the last frame sent downstream, the tracker applies the frames
+	// of the source in this order
+	lastSentFrameId int64
+	// Frames sent to the tracker after the detector acked them, queued so
+	// the acks of the other sources are not held up by the tracker
+	forwards      chan *pb.FrameData
+	forwarderOnce sync.Once
+}
+
+// captureMu serializes opening captures since the FFmpeg options are passed
+// to OpenCV through the environment
+var captureMu sync.Mutex
+
+// openCapture opens the video source of the config with its RTSP transport,
+// the lock is taken without a transport too since FFmpeg reads the options
+// of another source while they are set
+func openCapture(config *Config) (*gocv.VideoCapture, error) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if config.Transport == "" {
+		return gocv.OpenVideoCapture(config.VideoSource)
+	}
+
+	prev, set := os.LookupEnv(ffmpegOptionsEnv)
+	os.Setenv(ffmpegOptionsEnv, "rtsp_transport;"+config.Transport)
+	defer func() {
+		if set {
+			os.Setenv(ffmpegOptionsEnv, prev)
+		} else {
+			os.Unsetenv(ffmpegOptionsEnv)
+		}
+	}()
+	return gocv.OpenVideoCapture(config.VideoSource)
 }
 
 // NewVideoInput creates and initializes a new VideoInput instance
-func NewVideoInput(config *Config, dtClient, trClient *utils.GrpcClient) (*VideoInput, error) {
+func NewVideoInput(config *Config, dtStream, trStream FrameSender, m *metric.Metric) (*VideoInput, error) {
 
-	log.Printf("Initializing video input with source: %s\n", config.VideoSource)
-	capture, err := gocv.OpenVideoCapture(config.VideoSource)
+	log.Printf("Initializing video input with source: %s\n", config.SourceId)
+	capture, err := openCapture(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open video source: %v", err)
 	}
-	log.Printf("Starting video input processing for source: %s\n", config.VideoSource)
+	log.Printf("Starting video input processing for source: %s\n", config.SourceId)
 
 	vi := &VideoInput{
-		config:          config,
-		grpcDtClientRef: dtClient,
-		grpcTrClientRef: trClient,
-		queue:           make(chan frameData, config.QueueSize),
-		Signal:          signal{Done: make(chan struct{})},
-		capture:         capture,
-		frameCount:      0,
+		config:     config,
+		dtStream:   dtStream,
+		trStream:   trStream,
+		queue:      make(chan frameData, config.QueueSize),
+		forwards:   make(chan *pb.FrameData, max(config.QueueSize, 1)),
+		Signal:     signal{Done: make(chan struct{})},
+		capture:    capture,
+		frameCount: 0,
+		metric:     m,
+		scheduler:  NewDetectionScheduler(config.DetectionFrequency, config.DetectionFrequencyMin, config.DetectionFrequencyMax),
+	}
+	if config.MotionGating {
+		vi.motion = NewMotionDetector(config.MotionThreshold, config.MotionRegions, config.MotionIdleInterval)
 	}
+	// The tracker reports the health of the tracks of this source in its acks
+	trStream.Listen(config.SourceId, func(ack *pb.Ack, _ *pb.FrameData) {
+		vi.scheduler.Observe(ack.Health)
+	})
+	dtStream.Listen(config.SourceId, vi.handleDetection)
+	m.SetSourceConnected(config.SourceId, true)
 
-	vi.wg.Add(2) // Add 2 to the WaitGroup for readFrames and processFrames
+	vi.wg.Add(3) // Add 3 to the WaitGroup for readFrames, processFrames and forwardFrames
 	go vi.readFrames()
 	go vi.processFrames()
+	go vi.forwardFrames()
 	// Close the video input when done
 	go vi.handleClose()
 
@@ -106,9 +199,12 @@ func (vi *VideoInput) readFrames() {
 				log.Println("Error reading frame")
 				emptyFrames++
 				if emptyFrames > 10 {
-					log.Println("Too many empty frames, stopping video input")
-					vi.Signal.Close() // Signal to stop processing and clean up
-					return
+					log.Println("Too many empty frames, reconnecting video input")
+					if !vi.reconnect() {
+						return
+					}
+					emptyFrames = 0
+					continue
 				}
 				time.Sleep(500 * time.Millisecond) // Wait before retrying
 				continue
@@ -116,15 +212,21 @@ func (vi *VideoInput) readFrames() {
 			emptyFrames = 0
 
 			// Resize the image, should not close the resized image as it is passed to the queue
-			// and should be closed in the consumer processFrames
+			// and should be closed in the consumer processFrames. Tiled frames keep their size.
 			resized := gocv.NewMat()
-			gocv.Resize(img, &resized, image.Pt(vi.config.ImageWidth, vi.config.ImageHeight), 0, 0, gocv.InterpolationDefault)
+			if vi.config.Tiling.GetEnabled() {
+				img.CopyTo(&resized)
+			} else {
+				gocv.Resize(img, &resized, image.Pt(vi.config.ImageWidth, vi.config.ImageHeight), 0, 0, gocv.InterpolationDefault)
+			}
 
+			vi.lastFrameId = vi.frameOffset + int64(vi.capture.Get(gocv.VideoCapturePosFrames))
 			frameData := frameData{
-				metadata: api.FrameMetadata{
+				metadata: &pb.FrameMetadata{
 					Timestamp: time.Now().Format(time.RFC3339Nano),
-					SourceId:  vi.config.VideoSource,
-					FrameId:   int64(vi.capture.Get(gocv.VideoCapturePosFrames)),
+					SourceId:  vi.config.SourceId,
+					FrameId:   vi.lastFrameId,
+					Tiling:    vi.config.Tiling,
 				},
 				frame: resized,
 			}
@@ -148,7 +250,7 @@ func (vi *VideoInput) readFrames() {
 			elapsed := float64(time.Since(startT).Milliseconds()) / 1000.0
 			sleepDuration := delay - elapsed
 			if vi.frameCount%100 == 0 {
-				log.Printf("[%d] frames processed. Time: %.2fs, Sleep: %.2fs, Skipped frames: [%d]\n", vi.frameCount, elapsed, sleepDuration, vi.frameSkipped)
+				log.Printf("[%d] frames processed. Time: %.2fs, Sleep: %.2fs, Skipped frames: [%d], Detection interval: [%d]\n", vi.frameCount, elapsed, sleepDuration, vi.frameSkipped, vi.scheduler.Interval())
 			}
 
 			if sleepDuration > 0 {
@@ -166,6 +268,54 @@ func (vi *VideoInput) readFrames() {
 	}
 }
 
+// reconnect reopens the video source, waiting with an exponential backoff
+// between the attempts. It returns false if the video input is stopped
+// before the source could be reopened.
+func (vi *VideoInput) reconnect() bool {
+	source := vi.config.SourceId
+	vi.metric.SetSourceConnected(source, false)
+	vi.capture.Close()
+	vi.capture = nil
+
+	backoff := vi.config.ReconnectMinBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+	maxBackoff := vi.config.ReconnectMaxBackoff
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-vi.Signal.Done:
+			return false
+		case <-time.After(backoff):
+		}
+
+		log.Printf("Reconnecting to [%s], attempt [%d]\n", source, attempt)
+		capture, err := openCapture(vi.config)
+		if err == nil && capture.IsOpened() {
+			vi.capture = capture
+			vi.frameOffset = vi.lastFrameId
+			vi.reconnects++
+			vi.metric.AddReconnect(source)
+			vi.metric.SetSourceConnected(source, true)
+			log.Printf("Reconnected to [%s] after [%d] attempts, reconnections: [%d]\n", source, attempt, vi.reconnects)
+			return true
+		}
+		if err == nil {
+			capture.Close()
+		}
+		log.Printf("Failed to reconnect to [%s]: %v, retrying in %v\n", source, err, backoff)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
 // processFrames processes frames from the queue
 func (vi *VideoInput) processFrames() {
 	defer vi.wg.Done()
@@ -177,34 +327,50 @@ func (vi *VideoInput) processFrames() {
 				return
 			}
 
-			buf, err := gocv.IMEncode(gocv.PNGFileExt, f.frame)
+			if vi.motion != nil {
+				send, started := vi.motion.Check(f.frame)
+				if started {
+					// Objects may have entered the scene
+					vi.scheduler.Force()
+				}
+				if !send {
+					vi.frameSuppressed++
+					vi.metric.AddSuppressedFrame(vi.config.SourceId)
+					f.frame.Close()
+					continue
+				}
+			}
+
+			buf, err := codec.Encode(f.frame, vi.config.Encoding, vi.config.EncodingQuality)
 			if err != nil {
 				log.Printf("Failed to encode frame: %v", err)
 				vi.frameSkipped++
 				f.frame.Close()
 				continue
 			}
+			f.metadata.Encoding = vi.config.Encoding
+			f.metadata.Width = int32(f.frame.Cols())
+			f.metadata.Height = int32(f.frame.Rows())
+			f.metadata.PreviousFrameId = vi.lastSentFrameId
 
-			var (
-				client  = vi.grpcTrClientRef
-				service = "tracker"
-			)
+			stream := vi.trStream
 
-			// Alternate between sending to the tracker and detector
-			if int(f.metadata.FrameId)%vi.config.DetectionFrequency == 0 {
-				client = vi.grpcDtClientRef
-				service = "detector"
+			// Send to the detector when the scheduler asks for it, otherwise
+			// the tracker follows the objects on its own
+			if vi.scheduler.ShouldDetect(f.metadata.FrameId) {
+				stream = vi.dtStream
 			}
 
-			// Send the frame to the remote service using gRPC
-			if err := SendFrame(f.metadata, buf.GetBytes(), client, service); err != nil {
+			// Send the frame to the remote service over its stream, this blocks
+			// while the in-flight window of the stream is full
+			if err := stream.Send(vi.Signal.Done, &pb.FrameData{Metadata: f.metadata, FrameData: buf}); err != nil {
 				log.Printf("failed to send frame: %v", err)
 				vi.frameSkipped++
 			} else {
 				vi.frameProcessed++
+				vi.lastSentFrameId = f.metadata.FrameId
 			}
 
-			buf.Close()
 			f.frame.Close() // Close the frame after processing
 
 		case <-vi.Signal.Done:
@@ -215,6 +381,81 @@ func (vi *VideoInput) processFrames() {
 	}
 }
 
+// handleDetection handles an ack of the detector. Every frame sent to the
+// detector reaches the tracker, which waits for it: the detector forwards it
+// or else the aggregator sends it, with the detections if the detector
+// returned any. A busy detector is asked less often for a while.
+func (vi *VideoInput) handleDetection(ack *pb.Ack, sent *pb.FrameData) {
+	if ack.Status == detectorBusy {
+		vi.scheduler.Busy()
+	}
+	if sent == nil {
+		return
+	}
+	if ack.Forwarded {
+		if vi.config.ForwardDetections {
+			vi.forwarderOnce.Do(func() {
+				log.Printf("[%s]: The detector forwards the detections too, DETECTION_FORWARDER must be set the same in both", ack.SourceId)
+			})
+		}
+		return
+	}
+
+	d := &pb.FrameData{
+		Metadata:  sent.Metadata,
+		FrameData: sent.FrameData,
+	}
+	switch {
+	case ack.Status == detectorBusy:
+		log.Printf("Frame [%d], [%s]: Detector is busy, sending the frame to the tracker", ack.FrameId, ack.SourceId)
+	case ack.Status != "ok":
+		log.Printf("Frame [%d], [%s]: Detection failed, sending the frame to the tracker: [%s]", ack.FrameId, ack.SourceId, ack.Status)
+	default:
+		d.Detection = ack.Detection
+		if !vi.config.ForwardDetections {
+			log.Printf("Frame [%d], [%s]: Not forwarded by the detector, sending the detections to the tracker", ack.FrameId, ack.SourceId)
+		}
+	}
+
+	// the tracker waits for every frame, so the acks wait while the tracker
+	// is a full queue behind rather than dropping it
+	select {
+	case vi.forwards <- d:
+		return
+	default:
+	}
+	log.Printf("Frame [%d], [%s]: Forwarding queue is full, waiting for the tracker", ack.FrameId, ack.SourceId)
+	select {
+	case vi.forwards <- d:
+	case <-vi.Signal.Done:
+	}
+}
+
+// forwardFrames sends the frames queued by handleDetection to the tracker
+func (vi *VideoInput) forwardFrames() {
+	defer vi.wg.Done()
+	for {
+		select {
+		case d := <-vi.forwards:
+			if err := vi.trStream.Send(vi.Signal.Done, d); err != nil {
+				log.Printf("failed to send frame [%d] to the tracker: %v", d.Metadata.FrameId, err)
+			}
+		case <-vi.Signal.Done:
+			return
+		}
+	}
+}
+
+// Stopped reports whether the video input has been stopped
+func (vi *VideoInput) Stopped() bool {
+	select {
+	case <-vi.Signal.Done:
+		return true
+	default:
+		return false
+	}
+}
+
 // handleClose waits for the done channel to be closed and then closes the video input
 func (vi *VideoInput) handleClose() {
 	// Wait until vi.done is closed
@@ -231,7 +472,16 @@ func (vi *VideoInput) Close() {
 	vi.wg.Wait() // wait for goroutines to finish: readFrames and processFrames
 
 	log.Printf("  Closing vi.capture...")
-	vi.capture.Close() // close video source
+	if vi.capture != nil {
+		vi.capture.Close() // close video source
+	}
+	vi.metric.RemoveSource(vi.config.SourceId)
+	vi.trStream.Listen(vi.config.SourceId, nil)
+	vi.dtStream.Listen(vi.config.SourceId, nil)
+	if vi.motion != nil {
+		vi.motion.Close()
+	}
+	log.Printf("  Frames sent: [%d], skipped: [%d], suppressed: [%d]", vi.frameProcessed, vi.frameSkipped, vi.frameSuppressed)
 
 	log.Printf("  Closing vi.queue channel...")
 	close(vi.queue) // close channel so there are no more frames will be added to the queue
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// MotionDetector finds motion in the frames of a source with MOG2 background
+// subtraction. Frames without motion are suppressed, except one in every
+// idleInterval frames so the tracker still sees the scene.
+type MotionDetector struct {
+	mog  gocv.BackgroundSubtractorMOG2
+	mask gocv.Mat
+	// Share of the pixels of a region that have to change to report motion
+	threshold float64
+	// Regions of the frame to watch, the whole frame when empty
+	regions      []image.Rectangle
+	idleInterval int
+
+	moving     bool
+	idleFrames int
+}
+
+// NewMotionDetector creates a motion detector, it must be closed after use
+func NewMotionDetector(threshold float64, regions []image.Rectangle, idleInterval int) *MotionDetector {
+	if threshold <= 0 {
+		threshold = 0.005
+	}
+	return &MotionDetector{
+		mog:          gocv.NewBackgroundSubtractorMOG2WithParams(500, 16, true),
+		mask:         gocv.NewMat(),
+		threshold:    threshold,
+		regions:      regions,
+		idleInterval: idleInterval,
+	}
+}
+
+// Check updates the background model with the frame. It reports whether the
+// frame should be sent downstream and whether motion has just started.
+func (md *MotionDetector) Check(frame gocv.Mat) (send, started bool) {
+	if err := md.mog.Apply(frame, &md.mask); err != nil {
+		// without a mask we cannot tell, so let the frame through
+		return true, false
+	}
+	// Shadows are marked with a lower value than the foreground
+	gocv.Threshold(md.mask, &md.mask, 200, 255, gocv.ThresholdBinary)
+
+	moving := md.hasMotion()
+	started = moving && !md.moving
+	md.moving = moving
+	if moving {
+		md.idleFrames = 0
+		return true, started
+	}
+
+	md.idleFrames++
+	if md.idleInterval > 0 && md.idleFrames%md.idleInterval == 0 {
+		return true, false
+	}
+	return false, false
+}
+
+// hasMotion reports whether any watched region of the mask changed enough
+func (md *MotionDetector) hasMotion() bool {
+	bounds := image.Rect(0, 0, md.mask.Cols(), md.mask.Rows())
+	if len(md.regions) == 0 {
+		return md.changed(bounds)
+	}
+	for _, r := range md.regions {
+		if md.changed(r.Intersect(bounds)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (md *MotionDetector) changed(r image.Rectangle) bool {
+	if r.Empty() {
+		return false
+	}
+	region := md.mask.Region(r)
+	defer region.Close()
+	return float64(gocv.CountNonZero(region)) >= md.threshold*float64(r.Dx()*r.Dy())
+}
+
+// Close releases the background model
+func (md *MotionDetector) Close() {
+	md.mog.Close()
+	md.mask.Close()
+}
+
+// ParseRegions parses regions given as "x0,y0,x1,y1" separated by ";"
+func ParseRegions(s string) ([]image.Rectangle, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var regions []image.Rectangle
+	for _, part := range strings.Split(s, ";") {
+		fields := strings.Split(strings.TrimSpace(part), ",")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid region: %s", part)
+		}
+		var c [4]int
+		for i, f := range fields {
+			v, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil {
+				return nil, fmt.Errorf("invalid region: %s", part)
+			}
+			c[i] = v
+		}
+		regions = append(regions, image.Rect(c[0], c[1], c[2], c[3]))
+	}
+	return regions, nil
+}
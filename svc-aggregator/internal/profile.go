@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"net/url"
+	"strings"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+)
+
+// sourceConfig builds the configuration of the source streaming at u from
+// its profile, the settings missing from the profile are taken from the
+// global config
+func (s *Server) sourceConfig(u *url.URL, p *pb.SourceProfile) Config {
+	g := s.GlovalConfig
+	cfg := Config{
+		QueueSize:             g.QueueSize,
+		FrameRate:             g.FrameRate,
+		MaxTotalFrames:        g.MaxTotalFrames,
+		DetectionFrequency:    g.DetectionFrequency,
+		DetectionFrequencyMin: g.DetectionFrequencyMin,
+		DetectionFrequencyMax: g.DetectionFrequencyMax,
+		ImageWidth:            g.ImageWidth,
+		ImageHeight:           g.ImageHeight,
+		StreamPath:            g.StreamPath,
+		MotionGating:          g.MotionGating,
+		MotionThreshold:       g.MotionThreshold,
+		MotionRegions:         g.MotionRegions,
+		MotionIdleInterval:    g.MotionIdleInterval,
+		Encoding:              g.Encoding,
+		EncodingQuality:       g.EncodingQuality,
+		ForwardDetections:     g.ForwardDetections,
+		ReconnectMinBackoff:   g.ReconnectMinBackoff,
+		ReconnectMaxBackoff:   g.ReconnectMaxBackoff,
+	}
+	if cfg.ImageWidth <= 0 || cfg.ImageHeight <= 0 {
+		cfg.ImageWidth, cfg.ImageHeight = 640, 360
+	}
+	if u.Path != "" {
+		cfg.StreamPath = u.Path
+	}
+	if cfg.StreamPath == "" {
+		cfg.StreamPath = "/stream"
+	}
+
+	if p != nil {
+		if p.ImageWidth > 0 && p.ImageHeight > 0 {
+			cfg.ImageWidth = int(p.ImageWidth)
+			cfg.ImageHeight = int(p.ImageHeight)
+		}
+		if p.FrameRate > 0 {
+			cfg.FrameRate = p.FrameRate
+		}
+		if p.DetectionFrequency > 0 {
+			cfg.DetectionFrequency = int(p.DetectionFrequency)
+		}
+		if p.QueueSize > 0 {
+			cfg.QueueSize = int(p.QueueSize)
+		}
+		if p.MaxTotalFrames > 0 {
+			cfg.MaxTotalFrames = int(p.MaxTotalFrames)
+		}
+		if p.Encoding != pb.FrameEncoding_FRAME_ENCODING_UNSPECIFIED {
+			cfg.Encoding = p.Encoding
+		}
+		if p.EncodingQuality > 0 {
+			cfg.EncodingQuality = int(p.EncodingQuality)
+		}
+		if p.Tiling != nil {
+			cfg.Tiling = p.Tiling
+		}
+	}
+	if !strings.HasPrefix(cfg.StreamPath, "/") {
+		cfg.StreamPath = "/" + cfg.StreamPath
+	}
+
+	source := *u
+	source.Path = cfg.StreamPath
+	if p.GetUsername() != "" {
+		source.User = url.UserPassword(p.GetUsername(), p.GetPassword())
+	}
+	cfg.VideoSource = source.String()
+
+	// The source id must not carry the credentials since it is logged and
+	// sent along with every frame
+	source.User = nil
+	cfg.SourceId = source.String()
+	return cfg
+}
+
+// Profile returns the settings of the config without the credentials
+func (c *Config) Profile() *pb.SourceProfile {
+	return &pb.SourceProfile{
+		ImageWidth:         int32(c.ImageWidth),
+		ImageHeight:        int32(c.ImageHeight),
+		FrameRate:          c.FrameRate,
+		DetectionFrequency: int32(c.DetectionFrequency),
+		QueueSize:          int32(c.QueueSize),
+		MaxTotalFrames:     int32(c.MaxTotalFrames),
+		StreamPath:         c.StreamPath,
+		Encoding:           c.Encoding,
+		EncodingQuality:    int32(c.EncodingQuality),
+		Tiling:             c.Tiling,
+	}
+}
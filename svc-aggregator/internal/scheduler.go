@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"sync"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+)
+
+var (
+	// Share of lost tracks above which the tracker needs a new detection
+	lostTracksRatio float32 = 0.2
+	// Mean confidence below which the tracks need a new detection
+	lowConfidence float32 = 0.4
+	// Mean motion in pixels per frame below which the scene is static
+	staticMotion float32 = 1.0
+)
+
+// DetectionScheduler decides which frames of a source are sent to the
+// detector. Frames are detected every interval frames; the interval grows
+// up to max while the scene is static and shrinks down to min when the
+// tracker reports lost tracks or low confidence, in which case a frame is
+// detected as soon as min frames have passed. While the detector is busy the
+// interval doubles up to max.
+type DetectionScheduler struct {
+	mu           sync.Mutex
+	base         int
+	min          int
+	max          int
+	interval     int
+	lastDetected int64
+	health       *pb.TrackerHealth
+	// force sends the next frame to the detector
+	force bool
+	// busy is set when the detector rejected a frame since it is overloaded
+	busy bool
+}
+
+// NewDetectionScheduler creates a scheduler detecting every base frames,
+// the interval is adapted within [minInterval, maxInterval]. The scheduler
+// is fixed to base when the bounds are not set.
+func NewDetectionScheduler(base, minInterval, maxInterval int) *DetectionScheduler {
+	if base <= 0 {
+		base = 1
+	}
+	if minInterval <= 0 || minInterval > base {
+		minInterval = base
+	}
+	if maxInterval < base {
+		maxInterval = base
+	}
+	return &DetectionScheduler{
+		base:         base,
+		min:          minInterval,
+		max:          maxInterval,
+		interval:     base,
+		lastDetected: -1,
+	}
+}
+
+// Observe records the latest health of the tracks reported by the tracker
+func (ds *DetectionScheduler) Observe(h *pb.TrackerHealth) {
+	if h == nil {
+		return
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.health = h
+}
+
+// Busy backs off from the detector after it rejected a frame
+func (ds *DetectionScheduler) Busy() {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.busy = true
+}
+
+// Force makes the next frame go to the detector
+func (ds *DetectionScheduler) Force() {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.force = true
+}
+
+// ShouldDetect reports whether the frame is sent to the detector
+func (ds *DetectionScheduler) ShouldDetect(frameId int64) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	since := frameId - ds.lastDetected
+	if ds.lastDetected >= 0 && since < 0 {
+		// the frame numbering restarted
+		since = int64(ds.interval)
+	}
+	unhealthy := ds.unhealthy()
+	if !ds.force && ds.lastDetected >= 0 && since < int64(ds.interval) && !(unhealthy && since >= int64(ds.min)) {
+		return false
+	}
+	ds.lastDetected = frameId
+	ds.force = false
+
+	// Adapt the interval once per detection
+	switch {
+	case ds.busy:
+		ds.interval = min(ds.max, ds.interval*2)
+		ds.busy = false
+	case unhealthy:
+		ds.interval = max(ds.min, ds.interval/2)
+	case ds.static():
+		ds.interval = min(ds.max, ds.interval+1)
+	case ds.interval > ds.base:
+		ds.interval--
+	case ds.interval < ds.base:
+		ds.interval++
+	}
+	return true
+}
+
+// Interval returns the current detection interval in frames
+func (ds *DetectionScheduler) Interval() int {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.interval
+}
+
+// unhealthy reports whether the tracker is losing the objects, ds.mu must be held
+func (ds *DetectionScheduler) unhealthy() bool {
+	h := ds.health
+	if h == nil || h.ActiveTracks == 0 {
+		return false
+	}
+	if float32(h.LostTracks)/float32(h.ActiveTracks) >= lostTracksRatio {
+		return true
+	}
+	return h.MeanConfidence > 0 && h.MeanConfidence < lowConfidence
+}
+
+// static reports whether the tracked objects hardly move, ds.mu must be held
+func (ds *DetectionScheduler) static() bool {
+	h := ds.health
+	return h != nil && h.LostTracks == 0 && h.MeanMotion < staticMotion
+}
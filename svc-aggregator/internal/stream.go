@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	metric "github.com/etesami/detection-tracking-system/pkg/metric"
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"github.com/etesami/detection-tracking-system/pkg/utils"
+)
+
+// FrameStream keeps a single long-lived StreamFrames stream to a downstream
+// service and matches the received acks back to the frames that were sent.
+// The number of unacknowledged frames is bounded by the in-flight window;
+// Send blocks while the window is full.
+type FrameStream struct {
+	name      string
+	clientRef *utils.GrpcClient
+	metric    *metric.Metric
+
+	// window holds one token per frame that is sent but not acknowledged yet
+	window chan struct{}
+
+	sendMu  sync.Mutex
+	mu      sync.Mutex
+	stream  pb.DetectionTrackingPipeline_StreamFramesClient
+	cancel  context.CancelFunc
+	pending map[string]*pb.FrameData
+
+	// listeners receive the acks of the frames of their source
+	listeners sync.Map // map[string]AckListener
+}
+
+// NewFrameStream creates a frame stream to the service referenced by clientRef.
+// The stream itself is opened lazily on the first Send.
+func NewFrameStream(name string, clientRef *utils.GrpcClient, maxInFlight int, m *metric.Metric) *FrameStream {
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+	return &FrameStream{
+		name:      name,
+		clientRef: clientRef,
+		metric:    m,
+		window:    make(chan struct{}, maxInFlight),
+		pending:   make(map[string]*pb.FrameData),
+	}
+}
+
+// AckListener is called with an ack of a source and the frame it acknowledges,
+// the frame is nil if it is not known
+type AckListener func(ack *pb.Ack, sent *pb.FrameData)
+
+// Listen registers a function called with every ack of the source,
+// a nil function removes the listener
+func (fs *FrameStream) Listen(sourceId string, fn AckListener) {
+	if fn == nil {
+		fs.listeners.Delete(sourceId)
+		return
+	}
+	fs.listeners.Store(sourceId, fn)
+}
+
+// Ready reports whether the client of the downstream service is connected
+func (fs *FrameStream) Ready() bool {
+	return fs.clientRef.Load() != nil
+}
+
+// InFlight returns the number of frames sent but not acknowledged yet
+func (fs *FrameStream) InFlight() int {
+	return len(fs.window)
+}
+
+func ackKey(sourceId string, frameId int64) string {
+	return fmt.Sprintf("%s/%d", sourceId, frameId)
+}
+
+// Send sends a frame to the downstream service over the stream, the sent
+// timestamp is set here. It waits for a free slot in the in-flight window or
+// until done is closed.
+func (fs *FrameStream) Send(done <-chan struct{}, d *pb.FrameData) error {
+	select {
+	case fs.window <- struct{}{}:
+	case <-done:
+		return fmt.Errorf("stopped while waiting for [%s]", fs.name)
+	}
+
+	fs.mu.Lock()
+	if fs.stream == nil {
+		if err := fs.open(); err != nil {
+			fs.mu.Unlock()
+			<-fs.window
+			return err
+		}
+	}
+	stream := fs.stream
+
+	f := d.Metadata
+	d.SentTimestamp = time.Now().Format(time.RFC3339Nano)
+	key := ackKey(f.SourceId, f.FrameId)
+	if _, dup := fs.pending[key]; dup {
+		// the frame ids of a source restart when it registers again, the
+		// replaced frame will never be matched so its slot is freed here
+		log.Printf("Frame [%d], [%s]: Replacing unacknowledged frame with the same id", f.FrameId, fs.name)
+		<-fs.window
+	}
+	fs.pending[key] = d
+	fs.mu.Unlock()
+
+	// Send must not be called concurrently on the same stream
+	fs.sendMu.Lock()
+	err := stream.Send(d)
+	fs.sendMu.Unlock()
+
+	if err != nil {
+		// the pending entry of this frame is released with the rest of them
+		fs.mu.Lock()
+		if fs.stream == stream {
+			fs.reset()
+		}
+		fs.mu.Unlock()
+		return fmt.Errorf("error sending frame to [%s]: %v", fs.name, err)
+	}
+	fs.metric.AddSentDataBytes(fs.name, float64(len(d.FrameData)))
+	return nil
+}
+
+// open starts a new stream, fs.mu must be held
+func (fs *FrameStream) open() error {
+	client := fs.clientRef.Load()
+	if client == nil {
+		return fmt.Errorf("client [%s] is not initialized", fs.name)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.StreamFrames(ctx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("error opening stream to [%s]: %v", fs.name, err)
+	}
+	fs.stream = stream
+	fs.cancel = cancel
+	go fs.receiveAcks(stream)
+	log.Printf("Opened frame stream to [%s]", fs.name)
+	return nil
+}
+
+// reset tears down the current stream and releases the in-flight window
+// held by frames that will never be acknowledged, fs.mu must be held
+func (fs *FrameStream) reset() {
+	if fs.cancel != nil {
+		fs.cancel()
+	}
+	fs.stream = nil
+	fs.cancel = nil
+	for key := range fs.pending {
+		delete(fs.pending, key)
+		<-fs.window
+	}
+}
+
+// receiveAcks reads acks from the stream until it fails
+func (fs *FrameStream) receiveAcks(stream pb.DetectionTrackingPipeline_StreamFramesClient) {
+	for {
+		ack, err := stream.Recv()
+		if err != nil {
+			log.Printf("Frame stream to [%s] closed: %v", fs.name, err)
+			fs.mu.Lock()
+			// only reset if a newer stream has not replaced this one already
+			if fs.stream == stream {
+				fs.reset()
+			}
+			fs.mu.Unlock()
+			return
+		}
+		fs.handleAck(ack)
+	}
+}
+
+func (fs *FrameStream) handleAck(ack *pb.Ack) {
+	ackRecTime := time.Now().Format(time.RFC3339Nano)
+
+	fs.mu.Lock()
+	key := ackKey(ack.SourceId, ack.FrameId)
+	d, found := fs.pending[key]
+	if found {
+		delete(fs.pending, key)
+		<-fs.window
+	}
+	fs.mu.Unlock()
+
+	if fn, ok := fs.listeners.Load(ack.SourceId); ok {
+		fn.(AckListener)(ack, d)
+	}
+	if !found {
+		log.Printf("Received ack for unknown frame [%d] from [%s]", ack.FrameId, fs.name)
+		return
+	}
+	if ack.Status != "ok" {
+		log.Printf("Sent frame [%d], [%s] response: [%s]", ack.FrameId, fs.name, ack.Status)
+		return
+	}
+
+	rtt, err := utils.CalculateRtt(d.SentTimestamp, ack.ReceivedTimestamp, ack.AckSentTimestamp, ackRecTime)
+	if err != nil {
+		log.Printf("error calculating RTT: %v", err)
+		return
+	}
+	fs.metric.AddRttTime(fs.name, rtt/1000.0)
+	log.Printf("Sent frame [%d], [%s] response: [%s], RTT [%.2f] ms\n", ack.FrameId, fs.name, ack.Status, rtt/1000.0)
+}
+
+// Close closes the stream
+func (fs *FrameStream) Close() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.stream != nil {
+		fs.sendMu.Lock()
+		fs.stream.CloseSend()
+		fs.sendMu.Unlock()
+	}
+	fs.reset()
+}
@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	api "github.com/etesami/detection-tracking-system/api"
 	metric "github.com/etesami/detection-tracking-system/pkg/metric"
@@ -48,20 +49,139 @@ func main() {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	width, _ := strconv.Atoi(os.Getenv("IMAGE_WIDTH"))
-	height, _ := strconv.Atoi(os.Getenv("IMAGE_HEIGHT"))
 	saveImageFrq, _ := strconv.Atoi(os.Getenv("SAVE_IMAGE_FREQUENCY"))
+	poolSize, _ := strconv.Atoi(os.Getenv("MODEL_POOL_SIZE"))
+	scoreThreshold, _ := strconv.ParseFloat(os.Getenv("SCORE_THRESHOLD"), 32)
+	nmsThreshold, _ := strconv.ParseFloat(os.Getenv("NMS_THRESHOLD"), 32)
+	maxDetections, _ := strconv.Atoi(os.Getenv("MAX_DETECTIONS"))
+	classThresholds, err := internal.ParseClassThresholds(os.Getenv("CLASS_THRESHOLDS"))
+	if err != nil {
+		log.Fatalf("Failed to parse class thresholds: %v", err)
+	}
+	tileWidth, _ := strconv.Atoi(os.Getenv("TILE_WIDTH"))
+	tileHeight, _ := strconv.Atoi(os.Getenv("TILE_HEIGHT"))
+	tileOverlap, _ := strconv.ParseFloat(os.Getenv("TILE_OVERLAP"), 64)
+	tileMerge, err := internal.ParseMergeMode(os.Getenv("TILE_MERGE"))
+	if err != nil {
+		log.Fatalf("Failed to parse tile merge mode: %v", err)
+	}
+
+	// The aggregator reads the same setting, so only one of them forwards
+	forwarder, err := utils.ParseDetectionForwarder(os.Getenv("DETECTION_FORWARDER"))
+	if err != nil {
+		log.Fatalf("Failed to parse detection forwarder: %v", err)
+	}
+	log.Printf("Detections are sent to the tracker by the [%s]", forwarder)
+
+	dtConfig := &internal.DtConfig{
+		SaveImage:          os.Getenv("SAVE_IMAGE") == "true",
+		SaveImagePath:      os.Getenv("SAVE_IMAGE_PATH"),
+		SaveImageFrequency: saveImageFrq,
+		ForwardToTracker:   forwarder == utils.ForwarderDetector,
+		Filter: internal.DetectionFilter{
+			Allow:           internal.ParseClassList(os.Getenv("CLASS_ALLOW")),
+			Deny:            internal.ParseClassList(os.Getenv("CLASS_DENY")),
+			ClassThresholds: classThresholds,
+			ScoreThreshold:  float32(scoreThreshold),
+			NMSThreshold:    float32(nmsThreshold),
+			PerClassNMS:     os.Getenv("NMS_PER_CLASS") == "true",
+			MaxDetections:   maxDetections,
+		},
+		Tiling: internal.Tiling{
+			Enabled:    os.Getenv("TILING") == "true",
+			TileWidth:  tileWidth,
+			TileHeight: tileHeight,
+			Overlap:    tileOverlap,
+			Merge:      tileMerge,
+		},
+	}
+
+	// The model is described by a manifest file, or by the environment if no
+	// manifest is given
+	var manifest *internal.Manifest
+	manifestPath := os.Getenv("MODEL_MANIFEST")
+	if manifestPath != "" {
+		manifest, err = internal.LoadManifest(manifestPath)
+		if err != nil {
+			log.Fatalf("Failed to load model manifest: %v", err)
+		}
+	} else {
+		width, _ := strconv.Atoi(os.Getenv("IMAGE_WIDTH"))
+		height, _ := strconv.Atoi(os.Getenv("IMAGE_HEIGHT"))
+		manifest = &internal.Manifest{
+			Model:       os.Getenv("YOLO_MODEL"),
+			Family:      os.Getenv("MODEL_FAMILY"),
+			InputWidth:  width,
+			InputHeight: height,
+			LabelFile:   os.Getenv("LABELS_FILE"),
+		}
+		if err := manifest.Complete(); err != nil {
+			log.Fatalf("Invalid model configuration: %v", err)
+		}
+	}
+	log.Printf("Using model [%s] version [%s], family [%s], input [%dx%d], [%d] class labels",
+		manifest.Name, manifest.Version, manifest.Family, manifest.InputWidth, manifest.InputHeight, len(manifest.Labels))
+
+	// Load the model once per worker, the models are replaced when the manifest
+	// file changes or a reload is requested
+	models, err := internal.NewModelManager(manifestPath, manifest, poolSize, m)
+	if err != nil {
+		log.Fatalf("Failed to load model pool: %v", err)
+	}
+	defer models.Close()
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	watchInterval, _ := strconv.Atoi(os.Getenv("MODEL_WATCH_INTERVAL_MS"))
+	go models.Watch(watchCtx, time.Duration(watchInterval)*time.Millisecond)
+
+	// Frames of concurrent requests are batched into a single forward pass if
+	// the batch size is larger than one
+	var batcher *internal.Batcher
+	batchSize, _ := strconv.Atoi(os.Getenv("BATCH_SIZE"))
+	batchWait, _ := strconv.Atoi(os.Getenv("BATCH_MAX_WAIT_MS"))
+	if batchSize > 1 {
+		batcher = internal.NewBatcher(models, batchSize, time.Duration(batchWait)*time.Millisecond)
+		log.Printf("Batching up to [%d] frames, waiting at most [%d] ms", batchSize, batchWait)
+	}
+
+	// A fixed number of workers runs the frames, frames arriving while the
+	// queue is full are rejected. By default every model of the pool has
+	// enough frames to fill a batch and as many frames wait in the queue.
+	inferenceWorkers, _ := strconv.Atoi(os.Getenv("INFERENCE_WORKERS"))
+	inferenceQueue, _ := strconv.Atoi(os.Getenv("INFERENCE_QUEUE_SIZE"))
+	if inferenceWorkers <= 0 {
+		inferenceWorkers = max(poolSize, 1) * max(batchSize, 1)
+	}
+	if inferenceQueue <= 0 {
+		inferenceQueue = inferenceWorkers
+	}
+	forwardWorkers, _ := strconv.Atoi(os.Getenv("FORWARD_WORKERS"))
+	forwardQueue, _ := strconv.Atoi(os.Getenv("FORWARD_QUEUE_SIZE"))
+	if forwardWorkers <= 0 {
+		forwardWorkers = 4
+	}
+	if forwardQueue <= 0 {
+		forwardQueue = 64
+	}
+	log.Printf("Running frames on [%d] workers with a queue of [%d] frames", inferenceWorkers, inferenceQueue)
+
+	// A stream has at most as many frames in flight as the detector accepts,
+	// the others are answered busy without starting a goroutine for them
+	streamWindow, _ := strconv.Atoi(os.Getenv("STREAM_MAX_IN_FLIGHT"))
+	if streamWindow <= 0 {
+		streamWindow = inferenceWorkers + inferenceQueue
+	}
 
 	s := &internal.Server{
 		TrackerClientRef: utils.GrpcClient{},
-		DtConfig: &internal.DtConfig{
-			Model:              os.Getenv("YOLO_MODEL"),
-			ImageWidth:         width,
-			ImageHeight:        height,
-			SaveImage:          os.Getenv("SAVE_IMAGE") == "true",
-			SaveImagePath:      os.Getenv("SAVE_IMAGE_PATH"),
-			SaveImageFrequency: saveImageFrq,
-		},
+		DtConfig:         dtConfig,
+		Models:           models,
+		Batcher:          batcher,
+		Inference:        internal.NewWorkerPool(inferenceWorkers, inferenceQueue),
+		Forwarding:       internal.NewWorkerPool(forwardWorkers, forwardQueue),
+		StreamWindow:     streamWindow,
+		Metric:           m,
 	}
 	grpcServer := grpc.NewServer()
 	pb.RegisterDetectionTrackingPipelineServer(grpcServer, s)
@@ -73,19 +193,22 @@ func main() {
 		}
 	}()
 
-	// Setup the remote service (tracker) to send processed frames
-	REMOTE_TRACKER_HOST := os.Getenv("REMOTE_TRACKER_HOST")
-	REMOTE_TRACKER_PORT := os.Getenv("REMOTE_TRACKER_PORT")
-	if REMOTE_TRACKER_HOST == "" || REMOTE_TRACKER_PORT == "" {
-		panic("REMOTE_TRACKER_HOST or REMOTE_TRACKER_PORT environment variable is not set")
-	}
+	// Setup the remote service (tracker) to send processed frames, it is not
+	// needed when the aggregator forwards the detections to the tracker
+	if dtConfig.ForwardToTracker {
+		REMOTE_TRACKER_HOST := os.Getenv("REMOTE_TRACKER_HOST")
+		REMOTE_TRACKER_PORT := os.Getenv("REMOTE_TRACKER_PORT")
+		if REMOTE_TRACKER_HOST == "" || REMOTE_TRACKER_PORT == "" {
+			panic("REMOTE_TRACKER_HOST or REMOTE_TRACKER_PORT environment variable is not set")
+		}
 
-	targetSvc := api.Service{
-		Address: REMOTE_TRACKER_HOST,
-		Port:    REMOTE_TRACKER_PORT,
-	}
+		targetSvc := api.Service{
+			Address: REMOTE_TRACKER_HOST,
+			Port:    REMOTE_TRACKER_PORT,
+		}
 
-	go utils.MonitorConnection1(targetSvc, &s.TrackerClientRef)
+		go utils.MonitorConnection1(targetSvc, &s.TrackerClientRef)
+	}
 
 	metricAddr := os.Getenv("METRIC_ADDR")
 	metricPort := os.Getenv("METRIC_PORT")
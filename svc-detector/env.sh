@@ -10,10 +10,63 @@ export REMOTE_TRACKER_PORT=5004
 export METRIC_ADDR=localhost
 export METRIC_PORT=8003
 
+# JSON manifest describing the model, YOLO_MODEL, MODEL_FAMILY, IMAGE_WIDTH,
+# IMAGE_HEIGHT and LABELS_FILE are used if not set
+export MODEL_MANIFEST=""
+# One of yolov5, yolov8, yolo11 or yolov10
+export MODEL_FAMILY="yolov8"
 export YOLO_MODEL="/home/ehsan/detection-tracking-system/svc-detector/other/yolov8n.onnx"
 export IMAGE_WIDTH=640
 export IMAGE_HEIGHT=640
 
 export SAVE_IMAGE="true"
 export SAVE_IMAGE_PATH="/tmp/imgs/"
-export SAVE_IMAGE_FREQUENCY=1
\ No newline at end of file
+export SAVE_IMAGE_FREQUENCY=1
+
+export MODEL_POOL_SIZE=2
+# Frames of concurrent requests run in batches of up to BATCH_SIZE frames, a
+# batch waits at most BATCH_MAX_WAIT_MS for more frames. Batching needs a model
+# exported with a dynamic batch size and is disabled if BATCH_SIZE is 1.
+export BATCH_SIZE=1
+export BATCH_MAX_WAIT_MS=5
+# Frames run on a fixed number of workers, frames arriving while the queue is
+# full are rejected as busy. The workers default to MODEL_POOL_SIZE times
+# BATCH_SIZE and the queue to the number of workers.
+export INFERENCE_WORKERS=0
+export INFERENCE_QUEUE_SIZE=0
+# Frames of a stream processed at once, the frames received beyond it are
+# answered busy. It defaults to INFERENCE_WORKERS plus INFERENCE_QUEUE_SIZE.
+export STREAM_MAX_IN_FLIGHT=0
+# Workers and queue sending the detections to the tracker, the aggregator
+# sends the frames the detector could not forward
+export FORWARD_WORKERS=4
+export FORWARD_QUEUE_SIZE=64
+# How often the manifest file is checked for changes, the model is reloaded
+# when it changes
+export MODEL_WATCH_INTERVAL_MS=2000
+# Service sending the detections to the tracker, "detector" or "aggregator",
+# it must be set the same in the aggregator
+export DETECTION_FORWARDER="detector"
+# One label per line, the COCO classes are used if not set
+export LABELS_FILE=""
+# Comma-separated class labels, all classes are reported if CLASS_ALLOW is empty
+export CLASS_ALLOW=""
+export CLASS_DENY=""
+# Comma-separated label=threshold pairs overriding SCORE_THRESHOLD per class.
+# The thresholds set here take precedence over the ones of the model manifest,
+# empty ones fall back to the manifest and then to 0.5 and 0.4.
+export CLASS_THRESHOLDS=""
+export SCORE_THRESHOLD=""
+export NMS_THRESHOLD=""
+export NMS_PER_CLASS="false"
+export MAX_DETECTIONS=0
+# Sliced inference over overlapping tiles plus the full frame, sources may
+# override it in their profile. The tile size is the model input if not set.
+# The aggregator keeps the native resolution only for the sources whose
+# profile enables tiling, other frames are resized and may fit in one tile.
+export TILING="false"
+export TILE_WIDTH=0
+export TILE_HEIGHT=0
+export TILE_OVERLAP=0.2
+# How the detections of the tiles are merged: nms or wbf
+export TILE_MERGE="nms"
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// defaultBatchWait is how long a batch waits for more frames if no wait is set
+const defaultBatchWait = 5 * time.Millisecond
+
+// runner runs the network over the views of a frame and returns the
+// candidates of every view together with the manifest of the model used
+type runner interface {
+	Run(ctx context.Context, views []view, minScore float32) ([]candidates, *Manifest, error)
+	// Manifest returns the manifest of the active model
+	Manifest() *Manifest
+}
+
+// Run borrows a model and runs it over the views of a frame one at a time,
+// which works with models exported with a fixed batch size of one
+func (mm *ModelManager) Run(ctx context.Context, views []view, minScore float32) ([]candidates, *Manifest, error) {
+	m, err := mm.Get(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mm.Put(m)
+
+	results := make([]candidates, 0, len(views))
+	for i := range views {
+		res, err := inferBatch(m, views[i:i+1], minScore)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, res...)
+	}
+	return results, m.Manifest, nil
+}
+
+// batchItem is a view queued for a batch, done is closed once its result is
+// set
+type batchItem struct {
+	view     view
+	minScore float32
+	result   candidates
+	manifest *Manifest
+	done     chan struct{}
+}
+
+// Batcher collects the views of the frames of concurrent requests into
+// batches and runs each batch with a single forward pass, which keeps the CPU
+// busier than running the frames one by one. A batch is run once it holds
+// maxSize views or maxWait has passed since its first view was queued. The
+// batches run concurrently on the models of the pool.
+type Batcher struct {
+	models  *ModelManager
+	maxSize int
+	maxWait time.Duration
+	items   chan *batchItem
+}
+
+// NewBatcher starts collecting batches of at most maxSize views, the models
+// must accept a dynamic batch size
+func NewBatcher(models *ModelManager, maxSize int, maxWait time.Duration) *Batcher {
+	if maxWait <= 0 {
+		maxWait = defaultBatchWait
+	}
+	b := &Batcher{
+		models:  models,
+		maxSize: maxSize,
+		maxWait: maxWait,
+		items:   make(chan *batchItem, maxSize),
+	}
+	go b.collect()
+	return b
+}
+
+// Manifest returns the manifest of the active model
+func (b *Batcher) Manifest() *Manifest {
+	return b.models.Manifest()
+}
+
+// Run queues the views of a frame and waits until the batches holding them
+// have run. The views of a frame may be split over several batches.
+func (b *Batcher) Run(ctx context.Context, views []view, minScore float32) ([]candidates, *Manifest, error) {
+	items := make([]*batchItem, 0, len(views))
+	for _, v := range views {
+		item := &batchItem{view: v, minScore: minScore, done: make(chan struct{})}
+		select {
+		case b.items <- item:
+			items = append(items, item)
+		case <-ctx.Done():
+			// the views queued already still refer to the image
+			b.wait(items)
+			return nil, nil, ctx.Err()
+		}
+	}
+	b.wait(items)
+
+	results := make([]candidates, len(items))
+	var man *Manifest
+	for i, item := range items {
+		results[i] = item.result
+		if man == nil {
+			man = item.manifest
+		}
+	}
+	if man == nil {
+		return nil, nil, items[0].result.err
+	}
+	return results, man, nil
+}
+
+// wait blocks until the items have run
+func (b *Batcher) wait(items []*batchItem) {
+	for _, item := range items {
+		<-item.done
+	}
+}
+
+// collect groups the queued views into batches for the lifetime of the service
+func (b *Batcher) collect() {
+	for {
+		batch := []*batchItem{<-b.items}
+		timer := time.NewTimer(b.maxWait)
+	fill:
+		for len(batch) < b.maxSize {
+			select {
+			case item := <-b.items:
+				batch = append(batch, item)
+			case <-timer.C:
+				break fill
+			}
+		}
+		timer.Stop()
+		go b.run(batch)
+	}
+}
+
+// run runs a batch on a model of the pool and hands the results to the
+// waiting requests
+func (b *Batcher) run(batch []*batchItem) {
+	defer func() {
+		for _, item := range batch {
+			close(item.done)
+		}
+	}()
+
+	m, err := b.models.Get(context.Background())
+	if err != nil {
+		for _, item := range batch {
+			item.result.err = err
+		}
+		return
+	}
+	defer b.models.Put(m)
+
+	views := make([]view, len(batch))
+	minScore := batch[0].minScore
+	for i, item := range batch {
+		views[i] = item.view
+		minScore = min(minScore, item.minScore)
+	}
+
+	start := time.Now()
+	results, err := inferBatch(m, views, minScore)
+	if err != nil {
+		log.Printf("Error running batch of [%d] views: %v", len(batch), err)
+		for _, item := range batch {
+			item.result.err = err
+		}
+		return
+	}
+	log.Printf("Ran batch of [%d] views in [%.2f] ms", len(batch), float64(time.Since(start).Microseconds())/1000.0)
+	for i, item := range batch {
+		item.result = results[i]
+		item.manifest = m.Manifest
+	}
+}
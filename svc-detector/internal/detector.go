@@ -2,109 +2,270 @@ package internal
 
 import (
 	"context"
-	"encoding/json"
-	"image"
+	"fmt"
+	"io"
 	"log"
+	"sync"
 	"time"
 
-	api "github.com/etesami/detection-tracking-system/api"
+	metric "github.com/etesami/detection-tracking-system/pkg/metric"
 	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
 	"github.com/etesami/detection-tracking-system/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Server struct {
 	pb.UnimplementedDetectionTrackingPipelineServer
 	TrackerClientRef utils.GrpcClient
 	DtConfig         *DtConfig
+	Models           *ModelManager
+	Batcher          *Batcher
+	// Inference runs the frames on a fixed number of workers, Forwarding
+	// sends the detections to the tracker
+	Inference  *WorkerPool
+	Forwarding *WorkerPool
+	// StreamWindow is the number of frames of a stream processed at once,
+	// the frames received beyond it are acknowledged as busy
+	StreamWindow int
+	Metric       *metric.Metric
 }
 
-type detectionData struct {
-	SourceId  string
-	Timestamp string
-	FrameId   int64
-	Boxes     []image.Rectangle
-}
-
-// YoloV8 detector model
+// Detector configuration, the model itself is described by its manifest
 type DtConfig struct {
-	Model              string
-	ImageWidth         int
-	ImageHeight        int
 	SaveImage          bool
 	SaveImagePath      string
 	SaveImageFrequency int
+	// Selects the reported detections by class, confidence and overlap
+	Filter DetectionFilter
+	// Default sliced inference, the sources may override it
+	Tiling Tiling
+	// Whether the detector sends the detected frames to the tracker itself,
+	// otherwise the aggregator forwards the detections it receives in the acks
+	ForwardToTracker bool
+
+	// sources whose frames fit in a single tile, warned about once
+	untiled sync.Map
 }
 
-// SendFrameServer handles incoming data from ingestion/aggregation services
-func (s *Server) SendFrameToServer(ctx context.Context, recData *pb.FrameData) (*pb.Ack, error) {
-	recTime := time.Now().Format(time.RFC3339Nano)
+// detect runs the detector on the frame and returns its detections
+func (s *Server) detect(ctx context.Context, recData *pb.FrameData) (*pb.DetectionResult, error) {
+	metadata := recData.GetMetadata()
+	if metadata == nil {
+		log.Printf("Frame metadata is missing")
+		return nil, fmt.Errorf("frame metadata is missing")
+	}
+
+	log.Printf("Frame [%d]: Received: [%d] Bytes\n", metadata.FrameId, len(recData.FrameData))
 
-	// unmarshal metadata into a struct
-	var metadata api.FrameMetadata
-	if err := json.Unmarshal([]byte(recData.Metadata), &metadata); err != nil {
-		log.Printf("Error unmarshalling metadata: %v", err)
+	// the frame runs on a model borrowed from the pool, on its own or batched
+	// with the frames of other requests if the batcher is set
+	var r runner = s.Models
+	if s.Batcher != nil {
+		r = s.Batcher
+	}
+
+	// process the frame data on a worker, the frame is rejected if all
+	// workers are busy and the queue is full
+	var boxes []*pb.BoundingBox
+	var man *Manifest
+	var procErr error
+	var procStart time.Time
+	err := s.Inference.Do(ctx, func() {
+		procStart = time.Now()
+		boxes, man, procErr = s.DtConfig.ProcessFrame(ctx, r, recData.FrameData, metadata)
+	})
+	if err == ErrBusy {
+		log.Printf("Frame [%d], [%s]: Rejected, [%d] frames queued", metadata.FrameId, metadata.SourceId, s.Inference.Queued())
+		s.Metric.AddDroppedFrame(metadata.SourceId, StatusBusy)
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err == nil {
+		err = procErr
+	}
+	if err != nil {
+		log.Printf("Frame [%d]: Error running detection: %v", metadata.FrameId, err)
 		return nil, err
 	}
+	s.Metric.AddProcessingTime("detector", float64(time.Since(procStart).Microseconds())/1000.0)
 
-	log.Printf("Frame [%d]: Received: [%d] Bytes\n", metadata.FrameId, len(recData.FrameData))
+	return &pb.DetectionResult{
+		Metadata:     metadata,
+		Boxes:        boxes,
+		ModelName:    man.Name,
+		ModelVersion: man.Version,
+	}, nil
+}
 
-	// process the frame data
-	iboxes, indicies := s.DtConfig.ProcessFrame(recData.FrameData, int(metadata.FrameId))
-	selectedBoxes := make([]image.Rectangle, 0, len(indicies))
-	// select only boxes with indicies
-	for i := range indicies {
-		if indicies[i] < 0 || indicies[i] >= len(iboxes) {
-			log.Printf("[Warning] Invalid index %d for boxes", indicies[i])
-			continue
-		}
-		selectedBoxes = append(selectedBoxes, iboxes[indicies[i]])
+// forward sends the frame with its detections to the tracker
+func (s *Server) forward(ctx context.Context, recData *pb.FrameData, detection *pb.DetectionResult) error {
+	metadata := detection.Metadata
+
+	c := s.TrackerClientRef.Load()
+	if c == nil {
+		return fmt.Errorf("tracker client is not initialized")
+	}
+
+	d := pb.FrameData{
+		Metadata:      metadata,
+		FrameData:     recData.FrameData,
+		Detection:     detection,
+		SentTimestamp: time.Now().Format(time.RFC3339Nano), // the current timestamp
+	}
+	pong, err := c.SendDetectedFrameToServer(ctx, &d)
+	if err != nil {
+		return fmt.Errorf("error sending frame to the tracker: %v", err)
+	}
+
+	rtt, err := utils.CalculateRtt(d.SentTimestamp, pong.ReceivedTimestamp, pong.AckSentTimestamp, time.Now().Format(time.RFC3339Nano))
+	if err != nil {
+		log.Printf("error calculating RTT: %v", err)
+	}
+	log.Printf("Sent frame [%d] with [%d] detections, response: [%s], RTT [%.2f] ms\n",
+		int(metadata.FrameId), len(detection.Boxes), pong.Status, float64(rtt)/1000.0)
+	return nil
+}
+
+// SendFrameServer handles incoming data from ingestion/aggregation services,
+// the detections are returned in the ack and forwarded to the tracker if the
+// detector is configured to do so. The ack is sent once the tracker has the
+// frame and tells whether it does, the aggregator sends the frames the
+// detector could not forward.
+func (s *Server) SendFrameToServer(ctx context.Context, recData *pb.FrameData) (*pb.Ack, error) {
+	recTime := time.Now().Format(time.RFC3339Nano)
+
+	detection, err := s.detect(ctx, recData)
+	if err != nil {
+		return nil, err
 	}
 
-	go func(sBoxes []image.Rectangle, metadata api.FrameMetadata) {
-		// construct the message for tracker service
-		m := detectionData{
-			SourceId:  metadata.SourceId,
-			Timestamp: metadata.Timestamp,
-			FrameId:   metadata.FrameId,
-			Boxes:     sBoxes,
+	forwarded := false
+	if s.DtConfig.ForwardToTracker {
+		var fwdErr error
+		err := s.Forwarding.Do(ctx, func() { fwdErr = s.forward(ctx, recData, detection) })
+		if err == nil {
+			err = fwdErr
 		}
-		mByte, err := json.Marshal(m)
+		forwarded = err == nil
 		if err != nil {
-			log.Printf("Error marshalling frame data: %v", err)
-			return
+			log.Printf("Frame [%d]: Not forwarded, the aggregator sends the detections: %v", detection.Metadata.FrameId, err)
 		}
+	}
+
+	ack := &pb.Ack{
+		Status:                "ok",
+		OriginalSentTimestamp: recData.SentTimestamp,
+		ReceivedTimestamp:     recTime,
+		AckSentTimestamp:      time.Now().Format(time.RFC3339Nano),
+		Detection:             detection,
+		Forwarded:             forwarded,
+	}
+
+	return ack, nil
+}
+
+// DetectFrame returns the detections of a frame to the caller without
+// forwarding the frame to the tracker
+func (s *Server) DetectFrame(ctx context.Context, recData *pb.FrameData) (*pb.DetectionResponse, error) {
+	recTime := time.Now().Format(time.RFC3339Nano)
+
+	detection, err := s.detect(ctx, recData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.DetectionResponse{
+		Status:                "ok",
+		Detection:             detection,
+		OriginalSentTimestamp: recData.SentTimestamp,
+		ReceivedTimestamp:     recTime,
+		AckSentTimestamp:      time.Now().Format(time.RFC3339Nano),
+	}, nil
+}
+
+// StreamFrames handles a long-lived stream of frames from the aggregator. Frames
+// are processed concurrently (bounded by StreamWindow) and each one is
+// acknowledged with its source and frame id once processed.
+func (s *Server) StreamFrames(stream pb.DetectionTrackingPipeline_StreamFramesServer) error {
+	var (
+		sendMu sync.Mutex
+		wg     sync.WaitGroup
+	)
+	defer wg.Wait()
+	slots := make(chan struct{}, max(s.StreamWindow, 1))
 
-		c := s.TrackerClientRef.Load()
-		if c == nil {
-			log.Println("Tracker client is not initialized")
-			return
+	sendAck := func(ack *pb.Ack, recData *pb.FrameData) {
+		ack.SourceId = recData.GetMetadata().GetSourceId()
+		ack.FrameId = recData.GetMetadata().GetFrameId()
+
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if err := stream.Send(ack); err != nil {
+			log.Printf("Frame [%d]: Error sending ack: %v", ack.FrameId, err)
 		}
+	}
 
-		d := pb.FrameData{
-			Metadata:      string(mByte),
-			FrameData:     recData.FrameData,
-			SentTimestamp: time.Now().Format(time.RFC3339Nano), // the current timestamp
+	for {
+		recData, err := stream.Recv()
+		if err == io.EOF {
+			return nil
 		}
-		pong, err := c.SendDetectedFrameToServer(context.Background(), &d)
 		if err != nil {
-			log.Printf("error sending frame to server: %v", err)
+			log.Printf("Error receiving from frame stream: %v", err)
+			return err
 		}
 
-		rtt, err := utils.CalculateRtt(d.SentTimestamp, pong.ReceivedTimestamp, pong.AckSentTimestamp, time.Now().Format(time.RFC3339Nano))
-		if err != nil {
-			log.Printf("error calculating RTT: %v", err)
+		// the frame is rejected before a goroutine is started for it
+		select {
+		case slots <- struct{}{}:
+		default:
+			metadata := recData.GetMetadata()
+			log.Printf("Frame [%d], [%s]: Rejected, [%d] frames of the stream in flight", metadata.GetFrameId(), metadata.GetSourceId(), len(slots))
+			s.Metric.AddDroppedFrame(metadata.GetSourceId(), StatusBusy)
+			sendAck(&pb.Ack{
+				Status:                StatusBusy,
+				OriginalSentTimestamp: recData.SentTimestamp,
+			}, recData)
+			continue
 		}
-		log.Printf("Sent frame [%d] with [%d] detections, response: [%s], RTT [%.2f] ms\n",
-			int(metadata.FrameId), len(sBoxes), pong.Status, float64(rtt)/1000.0)
 
-	}(selectedBoxes, metadata)
+		wg.Add(1)
+		go func(recData *pb.FrameData) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			ack, err := s.SendFrameToServer(stream.Context(), recData)
+			if err != nil {
+				ackStatus := fmt.Sprintf("error: %v", err)
+				if status.Code(err) == codes.ResourceExhausted {
+					// the sender backs off instead of treating it as a failure
+					ackStatus = StatusBusy
+				}
+				ack = &pb.Ack{
+					Status:                ackStatus,
+					OriginalSentTimestamp: recData.SentTimestamp,
+				}
+			}
+			sendAck(ack, recData)
+		}(recData)
+	}
+}
 
-	ack := &pb.Ack{
-		Status:                "ok",
-		OriginalSentTimestamp: recData.SentTimestamp,
-		ReceivedTimestamp:     recTime,
-		AckSentTimestamp:      time.Now().Format(time.RFC3339Nano),
+// ReloadModel loads the model manifest again and swaps the models, the
+// response describes the active models, which are kept if the reload fails
+func (s *Server) ReloadModel(ctx context.Context, req *pb.Data) (*pb.ModelInfo, error) {
+	log.Printf("Model reload requested")
+	status := "ok"
+	if _, err := s.Models.Reload(); err != nil {
+		log.Printf("Error reloading model: %v", err)
+		status = fmt.Sprintf("error: %v", err)
 	}
 
-	return ack, nil
+	man := s.Models.Manifest()
+	return &pb.ModelInfo{
+		Status:          status,
+		Name:            man.Name,
+		Version:         man.Version,
+		Family:          man.Family,
+		LoadedTimestamp: s.Models.LoadedAt().Format(time.RFC3339Nano),
+	}, nil
 }
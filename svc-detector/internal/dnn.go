@@ -1,54 +1,39 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"time"
 
+	"github.com/etesami/detection-tracking-system/pkg/codec"
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"github.com/etesami/detection-tracking-system/pkg/utils"
 	"gocv.io/x/gocv"
 )
 
-var (
-	ratio    = 0.003921568627
-	mean     = gocv.NewScalar(0, 0, 0, 0)
-	swapRGB  = false
-	padValue = gocv.NewScalar(144.0, 0, 0, 0)
+// padValue fills the borders of the letterboxed network input
+var padValue = gocv.NewScalar(144.0, 0, 0, 0)
 
-	scoreThreshold float32 = 0.5
-	nmsThreshold   float32 = 0.4
-)
-
-func (c *DtConfig) ProcessFrame(frame []byte, frameId int) ([]image.Rectangle, []int) {
-	backend := gocv.NetBackendDefault
-	target := gocv.NetTargetCPU
-
-	img, err := gocv.IMDecode(frame, gocv.IMReadColor)
+// ProcessFrame decodes the frame and runs the detection with the runner, it
+// returns the boxes kept by NMS with their class and confidence and the
+// manifest of the model that detected them. A frame that cannot be decoded or
+// whose network output cannot be decoded fails.
+func (c *DtConfig) ProcessFrame(ctx context.Context, r runner, frame []byte, metadata *pb.FrameMetadata) ([]*pb.BoundingBox, *Manifest, error) {
+	frameId := int(metadata.FrameId)
+	img, err := codec.Decode(frame, metadata)
 	if err != nil {
-		log.Printf("Error decoding image: %v", err)
-		return nil, nil
+		return nil, nil, fmt.Errorf("error decoding image: %v", err)
 	}
 	defer img.Close()
 
-	// open DNN object tracking model
-	net := gocv.ReadNetFromONNX(c.Model)
-	if net.Empty() {
-		log.Printf("Error reading network model from : %v\n", c.Model)
-		return nil, nil
-	}
-	defer net.Close()
-	net.SetPreferableBackend(gocv.NetBackendType(backend))
-	net.SetPreferableTarget(gocv.NetTargetType(target))
-
-	outputNames := getOutputNames(&net)
-	if len(outputNames) == 0 {
-		log.Println("Error reading output layer names")
-		return nil, nil
+	boxes, man, err := c.detect(ctx, r, &img, metadata.SourceId, c.Tiling.forSource(metadata.Tiling))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	boxes, indicies := c.detect(&net, &img, outputNames)
-
 	if c.SaveImage && frameId%c.SaveImageFrequency == 0 {
 		timestamp := time.Now().UnixNano()
 		filename := fmt.Sprintf("%s/%d_detector.jpg", c.SaveImagePath, timestamp)
@@ -57,36 +42,120 @@ func (c *DtConfig) ProcessFrame(frame []byte, frameId int) ([]image.Rectangle, [
 		}
 		log.Printf("Frame [%d]: Detected %d objects, writtent to [%d_detector.jpg]", frameId, len(boxes), timestamp)
 	}
-	return boxes, indicies
+	return boxes, man, nil
+}
+
+func (c *DtConfig) detect(ctx context.Context, r runner, src *gocv.Mat, sourceId string, tiling Tiling) ([]*pb.BoundingBox, *Manifest, error) {
+	// candidates below the lowest threshold of the active model are discarded
+	// while decoding, the model may be swapped before the frame runs
+	active := r.Manifest()
+	minScore := c.Filter.forModel(active).minThreshold()
+
+	// the full frame, followed by the tiles of a sliced inference
+	views := []view{{src: src, rect: image.Rect(0, 0, src.Cols(), src.Rows())}}
+	tiles := tiling.tiles(src.Cols(), src.Rows(), active)
+	for _, tile := range tiles {
+		views = append(views, view{src: src, rect: tile})
+	}
+	if tiling.Enabled && len(tiles) == 0 {
+		if _, warned := c.untiled.LoadOrStore(sourceId, true); !warned {
+			log.Printf("[%s]: Tiling is enabled but the [%dx%d] frames fit in a single tile", sourceId, src.Cols(), src.Rows())
+		}
+	}
+
+	results, man, err := r.Run(ctx, views, minScore)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var boxes []image.Rectangle
+	var confidences []float32
+	var classIds []int
+	for _, res := range results {
+		if res.err != nil {
+			return nil, nil, fmt.Errorf("error decoding [%s] output: %v", man.Family, res.err)
+		}
+		boxes = append(boxes, res.boxes...)
+		confidences = append(confidences, res.confidences...)
+		classIds = append(classIds, res.classIds...)
+	}
+	if len(boxes) == 0 {
+		log.Println("No classes detected")
+		return nil, man, nil
+	}
+
+	// the views overlap, so their detections are merged even if the model
+	// suppresses overlapping boxes itself
+	filter := c.Filter.forModel(man)
+	nms := !man.decoder.NMSFree() || len(views) > 1
+	if len(views) > 1 && tiling.Merge == MergeWBF {
+		boxes, confidences, classIds = fuseBoxes(boxes, confidences, classIds, filter.nmsThreshold())
+		nms = false
+	}
+
+	labels := make([]string, len(classIds))
+	for i, classId := range classIds {
+		labels[i] = man.label(classId)
+	}
+	indices := filter.Apply(boxes, confidences, labels, nms)
+	drawRects(src, boxes, labels, indices)
+
+	detections := make([]*pb.BoundingBox, 0, len(indices))
+	for _, idx := range indices {
+		box := utils.RectToBox(boxes[idx])
+		box.ClassId = int32(classIds[idx])
+		box.Label = labels[idx]
+		box.Confidence = confidences[idx]
+		detections = append(detections, box)
+	}
+	return detections, man, nil
+}
+
+// view is a region of an image the network runs over
+type view struct {
+	src  *gocv.Mat
+	rect image.Rectangle
 }
 
-func (c *DtConfig) detect(net *gocv.Net, src *gocv.Mat, outputNames []string) ([]image.Rectangle, []int) {
-	params := gocv.NewImageToBlobParams(ratio, image.Pt(c.ImageWidth, c.ImageHeight), mean, swapRGB, gocv.MatTypeCV32F, gocv.DataLayoutNCHW, gocv.PaddingModeLetterbox, padValue)
-	blob := gocv.BlobFromImageWithParams(*src, params)
+// candidates are the boxes decoded from the output of the network for a
+// view, in the coordinates of its image
+type candidates struct {
+	boxes       []image.Rectangle
+	confidences []float32
+	classIds    []int
+	err         error
+}
+
+// inferBatch runs the network once over all views and returns the candidates
+// of each view, the model must accept a batch of that size
+func inferBatch(m *Model, views []view, minScore float32) ([]candidates, error) {
+	imgs := make([]gocv.Mat, len(views))
+	for i, v := range views {
+		if v.rect == image.Rect(0, 0, v.src.Cols(), v.src.Rows()) {
+			imgs[i] = *v.src
+			continue
+		}
+		imgs[i] = v.src.Region(v.rect)
+		defer imgs[i].Close()
+	}
+
+	params := m.Manifest.blobParams()
+	blob := gocv.NewMat()
 	defer blob.Close()
+	gocv.BlobFromImagesWithParams(imgs, &blob, params)
 
 	// feed the blob into the detector
-	net.SetInput(blob, "")
+	m.Net.SetInput(blob, "")
 
 	// run a forward pass thru the network
-	probs := net.ForwardLayers(outputNames)
+	probs := m.Net.ForwardLayers(m.OutputNames)
 	defer func() {
 		for _, prob := range probs {
 			prob.Close()
 		}
 	}()
 
-	boxes, confidences, classIds := performDetection(probs)
-	if len(boxes) == 0 {
-		log.Println("No classes detected")
-		return nil, nil
-	}
-
-	iboxes := params.BlobRectsToImageRects(boxes, image.Pt(src.Cols(), src.Rows()))
-	indices := gocv.NMSBoxes(iboxes, confidences, scoreThreshold, nmsThreshold)
-	drawRects(src, iboxes, classes, classIds, indices)
-
-	return iboxes, indices
+	return performDetection(m.Manifest.decoder, probs, views, params, minScore)
 }
 
 func getOutputNames(net *gocv.Net) []string {
@@ -102,55 +171,47 @@ func getOutputNames(net *gocv.Net) []string {
 	return outputLayers
 }
 
-func performDetection(outs []gocv.Mat) ([]image.Rectangle, []float32, []int) {
-	var classIds []int
-	var confidences []float32
-	var boxes []image.Rectangle
-
-	// needed for yolov8
-	gocv.TransposeND(outs[0], []int{0, 2, 1}, &outs[0])
-
-	for _, out := range outs {
-		out = out.Reshape(1, out.Size()[1])
-
-		for i := 0; i < out.Rows(); i++ {
-			cols := out.Cols()
-			scoresCol := out.RowRange(i, i+1)
-
-			scores := scoresCol.ColRange(4, cols)
-			_, confidence, _, classIDPoint := gocv.MinMaxLoc(scores)
-
-			if confidence > 0.5 {
-				centerX := out.GetFloatAt(i, cols)
-				centerY := out.GetFloatAt(i, cols+1)
-				width := out.GetFloatAt(i, cols+2)
-				height := out.GetFloatAt(i, cols+3)
-
-				left := centerX - width/2
-				top := centerY - height/2
-				right := centerX + width/2
-				bottom := centerY + height/2
-				classIds = append(classIds, classIDPoint.X)
-				confidences = append(confidences, float32(confidence))
-
-				boxes = append(boxes, image.Rect(int(left), int(top), int(right), int(bottom)))
-			}
+// performDetection splits the first output of the network by the views of the
+// batch and decodes the candidates of each view with a confidence of at least
+// minScore
+func performDetection(decoder Decoder, outs []gocv.Mat, views []view, params gocv.ImageToBlobParams, minScore float32) ([]candidates, error) {
+	if len(outs) == 0 {
+		return nil, fmt.Errorf("network has no output")
+	}
+	data, err := outs[0].DataPtrFloat32()
+	if err != nil {
+		return nil, err
+	}
+	shape := outs[0].Size()
+	if len(shape) == 0 || shape[0] != len(views) || len(data)%len(views) != 0 {
+		return nil, fmt.Errorf("output shape %v does not match a batch of [%d]", shape, len(views))
+	}
+	// every view is decoded as a batch of one
+	viewShape := append([]int{1}, shape[1:]...)
+	per := len(data) / len(views)
+
+	results := make([]candidates, len(views))
+	for i, v := range views {
+		boxes, confidences, classIds, err := decoder.Decode(data[i*per:(i+1)*per], viewShape, minScore)
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		if len(boxes) == 0 {
+			continue
+		}
+		boxes = params.BlobRectsToImageRects(boxes, v.rect.Size())
+		for k := range boxes {
+			boxes[k] = boxes[k].Add(v.rect.Min)
 		}
+		results[i] = candidates{boxes: boxes, confidences: confidences, classIds: classIds}
 	}
-
-	return boxes, confidences, classIds
+	return results, nil
 }
 
-func drawRects(img *gocv.Mat, boxes []image.Rectangle, classes []string, classIds []int, indices []int) []string {
-	var detectClass []string
+func drawRects(img *gocv.Mat, boxes []image.Rectangle, labels []string, indices []int) {
 	for _, idx := range indices {
-		if idx == 0 {
-			continue
-		}
-		gocv.Rectangle(img, image.Rect(boxes[idx].Min.X, boxes[idx].Min.Y, boxes[idx].Max.X, boxes[idx].Max.Y), color.RGBA{0, 255, 0, 0}, 2)
-		gocv.PutText(img, classes[classIds[idx]], image.Point{boxes[idx].Min.X, boxes[idx].Min.Y - 10}, gocv.FontHersheyPlain, 0.6, color.RGBA{0, 255, 0, 0}, 1)
-		detectClass = append(detectClass, classes[classIds[idx]])
+		gocv.Rectangle(img, boxes[idx], color.RGBA{0, 255, 0, 0}, 2)
+		gocv.PutText(img, labels[idx], image.Point{boxes[idx].Min.X, boxes[idx].Min.Y - 10}, gocv.FontHersheyPlain, 0.6, color.RGBA{0, 255, 0, 0}, 1)
 	}
-
-	return detectClass
 }
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+)
+
+// Model families with a known output layout
+const (
+	FamilyYOLOv5  = "yolov5"
+	FamilyYOLOv8  = "yolov8"
+	FamilyYOLO11  = "yolo11"
+	FamilyYOLOv10 = "yolov10"
+)
+
+// Decoder turns the output of a model into candidate boxes in the
+// coordinates of the network input
+type Decoder interface {
+	// Decode returns the candidates with a confidence of at least minScore,
+	// out is the flattened output tensor and shape its dimensions
+	Decode(out []float32, shape []int, minScore float32) ([]image.Rectangle, []float32, []int, error)
+	// NMSFree reports whether the model suppresses overlapping boxes itself
+	NMSFree() bool
+}
+
+// NewDecoder returns the decoder of a model family, YOLOv8 is used if the
+// family is empty
+func NewDecoder(family string) (Decoder, error) {
+	switch family {
+	case "", FamilyYOLOv8, FamilyYOLO11:
+		return yoloV8Decoder{}, nil
+	case FamilyYOLOv5:
+		return yoloV5Decoder{}, nil
+	case FamilyYOLOv10:
+		return yoloV10Decoder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown model family: %s", family)
+	}
+}
+
+// outputDims checks that the output has the shape [1, rows, cols]
+func outputDims(out []float32, shape []int) (rows, cols int, err error) {
+	if len(shape) != 3 || shape[0] != 1 {
+		return 0, 0, fmt.Errorf("unexpected output shape %v", shape)
+	}
+	rows, cols = shape[1], shape[2]
+	if rows*cols != len(out) {
+		return 0, 0, fmt.Errorf("output shape %v does not match [%d] values", shape, len(out))
+	}
+	return rows, cols, nil
+}
+
+// centerRect converts a box given by its centre and size into a rectangle
+func centerRect(cx, cy, w, h float32) image.Rectangle {
+	return image.Rect(int(cx-w/2), int(cy-h/2), int(cx+w/2), int(cy+h/2))
+}
+
+// yoloV8Decoder decodes outputs of shape [1, 4+classes, anchors], each
+// column holds the box centre and size followed by the class scores. YOLO11
+// shares the layout.
+type yoloV8Decoder struct{}
+
+func (yoloV8Decoder) NMSFree() bool { return false }
+
+func (yoloV8Decoder) Decode(out []float32, shape []int, minScore float32) ([]image.Rectangle, []float32, []int, error) {
+	rows, anchors, err := outputDims(out, shape)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if rows <= 4 {
+		return nil, nil, nil, fmt.Errorf("output shape %v has no class scores", shape)
+	}
+	at := func(row, i int) float32 { return out[row*anchors+i] }
+
+	var boxes []image.Rectangle
+	var confidences []float32
+	var classIds []int
+	for i := 0; i < anchors; i++ {
+		classId, score := 0, at(4, i)
+		for c := 1; c < rows-4; c++ {
+			if s := at(4+c, i); s > score {
+				classId, score = c, s
+			}
+		}
+		if score < minScore {
+			continue
+		}
+		boxes = append(boxes, centerRect(at(0, i), at(1, i), at(2, i), at(3, i)))
+		confidences = append(confidences, score)
+		classIds = append(classIds, classId)
+	}
+	return boxes, confidences, classIds, nil
+}
+
+// yoloV5Decoder decodes outputs of shape [1, anchors, 5+classes], each row
+// holds the box centre and size, the objectness and the class scores. The
+// confidence is the objectness times the class score.
+type yoloV5Decoder struct{}
+
+func (yoloV5Decoder) NMSFree() bool { return false }
+
+func (yoloV5Decoder) Decode(out []float32, shape []int, minScore float32) ([]image.Rectangle, []float32, []int, error) {
+	anchors, cols, err := outputDims(out, shape)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if cols <= 5 {
+		return nil, nil, nil, fmt.Errorf("output shape %v has no class scores", shape)
+	}
+
+	var boxes []image.Rectangle
+	var confidences []float32
+	var classIds []int
+	for i := 0; i < anchors; i++ {
+		row := out[i*cols : (i+1)*cols]
+		objectness := row[4]
+		if objectness < minScore {
+			continue
+		}
+		classId, score := 0, row[5]
+		for c := 1; c < cols-5; c++ {
+			if row[5+c] > score {
+				classId, score = c, row[5+c]
+			}
+		}
+		confidence := objectness * score
+		if confidence < minScore {
+			continue
+		}
+		boxes = append(boxes, centerRect(row[0], row[1], row[2], row[3]))
+		confidences = append(confidences, confidence)
+		classIds = append(classIds, classId)
+	}
+	return boxes, confidences, classIds, nil
+}
+
+// yoloV10Decoder decodes outputs of shape [1, detections, 6], each row holds
+// the box corners, the confidence and the class id. The model does not need
+// NMS.
+type yoloV10Decoder struct{}
+
+func (yoloV10Decoder) NMSFree() bool { return true }
+
+func (yoloV10Decoder) Decode(out []float32, shape []int, minScore float32) ([]image.Rectangle, []float32, []int, error) {
+	detections, cols, err := outputDims(out, shape)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if cols != 6 {
+		return nil, nil, nil, fmt.Errorf("unexpected output shape %v", shape)
+	}
+
+	var boxes []image.Rectangle
+	var confidences []float32
+	var classIds []int
+	for i := 0; i < detections; i++ {
+		row := out[i*cols : (i+1)*cols]
+		if row[4] < minScore {
+			continue
+		}
+		boxes = append(boxes, image.Rect(int(row[0]), int(row[1]), int(row[2]), int(row[3])))
+		confidences = append(confidences, row[4])
+		classIds = append(classIds, int(row[5]))
+	}
+	return boxes, confidences, classIds, nil
+}
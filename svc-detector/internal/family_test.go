@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"image"
+	"slices"
+	"testing"
+)
+
+// decoded is the output of a decoder
+type decoded struct {
+	boxes       []image.Rectangle
+	confidences []float32
+	classIds    []int
+}
+
+func TestDecoders(t *testing.T) {
+	tests := []struct {
+		name     string
+		family   string
+		out      []float32
+		shape    []int
+		minScore float32
+		want     decoded
+		wantErr  bool
+	}{
+		{
+			// [1, 4+2 classes, 3 anchors], one row per value
+			name:   "yolov8 picks the best class above the threshold",
+			family: FamilyYOLOv8,
+			out: []float32{
+				50, 20, 100, // cx
+				40, 20, 100, // cy
+				20, 10, 100, // w
+				10, 10, 100, // h
+				0.9, 0.1, 0.3, // class 0
+				0.2, 0.8, 0.4, // class 1
+			},
+			shape:    []int{1, 6, 3},
+			minScore: 0.5,
+			want: decoded{
+				boxes:       []image.Rectangle{image.Rect(40, 35, 60, 45), image.Rect(15, 15, 25, 25)},
+				confidences: []float32{0.9, 0.8},
+				classIds:    []int{0, 1},
+			},
+		},
+		{
+			name:     "yolov8 keeps a score equal to the threshold",
+			family:   FamilyYOLO11,
+			out:      []float32{10, 10, 4, 4, 0.5},
+			shape:    []int{1, 5, 1},
+			minScore: 0.5,
+			want: decoded{
+				boxes:       []image.Rectangle{image.Rect(8, 8, 12, 12)},
+				confidences: []float32{0.5},
+				classIds:    []int{0},
+			},
+		},
+		{
+			name:     "yolov8 without class scores",
+			family:   FamilyYOLOv8,
+			out:      []float32{10, 10, 4, 4},
+			shape:    []int{1, 4, 1},
+			minScore: 0.5,
+			wantErr:  true,
+		},
+		{
+			name:     "yolov8 shape does not match the output",
+			family:   "",
+			out:      []float32{10, 10, 4, 4, 0.9},
+			shape:    []int{1, 5, 2},
+			minScore: 0.5,
+			wantErr:  true,
+		},
+		{
+			// [1, 3 anchors, 5+2 classes], one row per anchor
+			name:   "yolov5 weights the class score by the objectness",
+			family: FamilyYOLOv5,
+			out: []float32{
+				50, 40, 20, 10, 0.75, 0.2, 0.75, // 0.5625 for class 1
+				20, 20, 10, 10, 0.3, 1.0, 0.0, // objectness below the threshold
+				80, 80, 10, 10, 0.6, 0.5, 0.4, // 0.3 below the threshold
+			},
+			shape:    []int{1, 3, 7},
+			minScore: 0.5,
+			want: decoded{
+				boxes:       []image.Rectangle{image.Rect(40, 35, 60, 45)},
+				confidences: []float32{0.5625},
+				classIds:    []int{1},
+			},
+		},
+		{
+			name:     "yolov5 without class scores",
+			family:   FamilyYOLOv5,
+			out:      []float32{10, 10, 4, 4, 0.9},
+			shape:    []int{1, 1, 5},
+			minScore: 0.5,
+			wantErr:  true,
+		},
+		{
+			name:     "yolov5 with a batch of two",
+			family:   FamilyYOLOv5,
+			out:      make([]float32, 12),
+			shape:    []int{2, 1, 6},
+			minScore: 0.5,
+			wantErr:  true,
+		},
+		{
+			// [1, 3 detections, 6], corners, confidence and class id
+			name:   "yolov10 reads the corners and the class id",
+			family: FamilyYOLOv10,
+			out: []float32{
+				10, 20, 30, 40, 0.9, 2,
+				50, 60, 70, 80, 0.4, 1,
+				5, 5, 15, 15, 0.6, 0,
+			},
+			shape:    []int{1, 3, 6},
+			minScore: 0.5,
+			want: decoded{
+				boxes:       []image.Rectangle{image.Rect(10, 20, 30, 40), image.Rect(5, 5, 15, 15)},
+				confidences: []float32{0.9, 0.6},
+				classIds:    []int{2, 0},
+			},
+		},
+		{
+			name:     "yolov10 with the yolov8 layout",
+			family:   FamilyYOLOv10,
+			out:      make([]float32, 14),
+			shape:    []int{1, 2, 7},
+			minScore: 0.5,
+			wantErr:  true,
+		},
+		{
+			name:     "no candidates above the threshold",
+			family:   FamilyYOLOv10,
+			out:      []float32{10, 20, 30, 40, 0.2, 2},
+			shape:    []int{1, 1, 6},
+			minScore: 0.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := NewDecoder(tt.family)
+			if err != nil {
+				t.Fatal(err)
+			}
+			boxes, confidences, classIds, err := dec.Decode(tt.out, tt.shape, tt.minScore)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got [%d] boxes", len(boxes))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(boxes, tt.want.boxes) {
+				t.Errorf("boxes %v, want %v", boxes, tt.want.boxes)
+			}
+			if !slices.Equal(confidences, tt.want.confidences) {
+				t.Errorf("confidences %v, want %v", confidences, tt.want.confidences)
+			}
+			if !slices.Equal(classIds, tt.want.classIds) {
+				t.Errorf("class ids %v, want %v", classIds, tt.want.classIds)
+			}
+		})
+	}
+}
+
+func TestNewDecoder(t *testing.T) {
+	tests := []struct {
+		family  string
+		nmsFree bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{FamilyYOLOv5, false, false},
+		{FamilyYOLOv8, false, false},
+		{FamilyYOLO11, false, false},
+		{FamilyYOLOv10, true, false},
+		{"ssd", false, true},
+	}
+	for _, tt := range tests {
+		dec, err := NewDecoder(tt.family)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("family [%s]: expected an error", tt.family)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("family [%s]: %v", tt.family, err)
+			continue
+		}
+		if dec.NMSFree() != tt.nmsFree {
+			t.Errorf("family [%s]: NMSFree %v, want %v", tt.family, dec.NMSFree(), tt.nmsFree)
+		}
+	}
+}
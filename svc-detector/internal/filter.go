@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/etesami/detection-tracking-system/pkg/utils"
+	"gocv.io/x/gocv"
+)
+
+// Defaults of the detection filter
+const (
+	defaultScoreThreshold float32 = 0.5
+	defaultNMSThreshold   float32 = 0.4
+)
+
+// DetectionFilter selects the detections reported by the detector
+type DetectionFilter struct {
+	// Only these classes are reported if the list is not empty
+	Allow []string
+	// These classes are never reported
+	Deny []string
+	// Minimum confidence of a detection, per class and for the other classes
+	ClassThresholds map[string]float32
+	ScoreThreshold  float32
+	// IoU above which overlapping boxes are suppressed, and whether boxes of
+	// different classes suppress each other
+	NMSThreshold float32
+	PerClassNMS  bool
+	// Maximum number of detections per frame, unlimited if not set
+	MaxDetections int
+}
+
+// ParseClassThresholds parses a comma-separated list of label=threshold pairs
+func ParseClassThresholds(env string) (map[string]float32, error) {
+	thresholds := make(map[string]float32)
+	for label, value := range utils.ParseLabels(env) {
+		t, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold for class '%s': %v", label, err)
+		}
+		thresholds[label] = float32(t)
+	}
+	return thresholds, nil
+}
+
+// ParseClassList parses a comma-separated list of class labels
+func ParseClassList(env string) []string {
+	var list []string
+	for _, p := range strings.Split(env, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// forModel returns the filter with the thresholds of the model manifest used
+// where the configuration does not set them, the configured thresholds take
+// precedence
+func (f *DetectionFilter) forModel(m *Manifest) *DetectionFilter {
+	if m.ScoreThreshold == 0 && m.NMSThreshold == 0 && len(m.ClassThresholds) == 0 {
+		return f
+	}
+	merged := *f
+	if f.ScoreThreshold == 0 {
+		merged.ScoreThreshold = m.ScoreThreshold
+	}
+	if f.NMSThreshold == 0 {
+		merged.NMSThreshold = m.NMSThreshold
+	}
+	if len(m.ClassThresholds) > 0 {
+		merged.ClassThresholds = make(map[string]float32, len(f.ClassThresholds)+len(m.ClassThresholds))
+		for label, t := range m.ClassThresholds {
+			merged.ClassThresholds[label] = t
+		}
+		for label, t := range f.ClassThresholds {
+			merged.ClassThresholds[label] = t
+		}
+	}
+	return &merged
+}
+
+// allowed reports whether detections of the class are reported at all
+func (f *DetectionFilter) allowed(label string) bool {
+	for _, l := range f.Deny {
+		if l == label {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, l := range f.Allow {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// threshold returns the minimum confidence of the class
+func (f *DetectionFilter) threshold(label string) float32 {
+	if t, ok := f.ClassThresholds[label]; ok {
+		return t
+	}
+	if f.ScoreThreshold > 0 {
+		return f.ScoreThreshold
+	}
+	return defaultScoreThreshold
+}
+
+// minThreshold returns the lowest confidence any class may be reported with,
+// candidates below it are discarded while decoding the network output
+func (f *DetectionFilter) minThreshold() float32 {
+	lowest := f.threshold("")
+	for _, t := range f.ClassThresholds {
+		if t < lowest {
+			lowest = t
+		}
+	}
+	return lowest
+}
+
+func (f *DetectionFilter) nmsThreshold() float32 {
+	if f.NMSThreshold > 0 {
+		return f.NMSThreshold
+	}
+	return defaultNMSThreshold
+}
+
+// Apply filters the candidate boxes by class and confidence, suppresses the
+// overlapping ones unless nms is false and returns the indices of the kept
+// boxes by decreasing confidence
+func (f *DetectionFilter) Apply(boxes []image.Rectangle, confidences []float32, labels []string, nms bool) []int {
+	// candidates of every NMS group, a single group for class-agnostic NMS
+	groups := make(map[string][]int)
+	for i, label := range labels {
+		if !f.allowed(label) || confidences[i] < f.threshold(label) {
+			continue
+		}
+		group := ""
+		if f.PerClassNMS {
+			group = label
+		}
+		groups[group] = append(groups[group], i)
+	}
+
+	var kept []int
+	for _, group := range groups {
+		if !nms {
+			kept = append(kept, group...)
+			continue
+		}
+		groupBoxes := make([]image.Rectangle, len(group))
+		groupScores := make([]float32, len(group))
+		for k, i := range group {
+			groupBoxes[k] = boxes[i]
+			groupScores[k] = confidences[i]
+		}
+		// the scores are filtered already, NMS only removes the overlaps
+		for _, k := range gocv.NMSBoxes(groupBoxes, groupScores, 0, f.nmsThreshold()) {
+			if k >= 0 && k < len(group) {
+				kept = append(kept, group[k])
+			}
+		}
+	}
+
+	sort.Slice(kept, func(a, b int) bool { return confidences[kept[a]] > confidences[kept[b]] })
+	if f.MaxDetections > 0 && len(kept) > f.MaxDetections {
+		kept = kept[:f.MaxDetections]
+	}
+	return kept
+}
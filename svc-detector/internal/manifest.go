@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"gocv.io/x/gocv"
+)
+
+// Manifest describes a model and how its input and output are handled
+type Manifest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Path of the ONNX model, relative paths are resolved against the
+	// directory of the manifest
+	Model string `json:"model"`
+	// Output layout of the model, see NewDecoder
+	Family      string `json:"family"`
+	InputWidth  int    `json:"input_width"`
+	InputHeight int    `json:"input_height"`
+	// Class labels indexed by class id, given inline or as a file with one
+	// label per line. The COCO classes are used if neither is set.
+	Labels        []string      `json:"labels"`
+	LabelFile     string        `json:"label_file"`
+	Normalization Normalization `json:"normalization"`
+	// Thresholds tuned for the model, they are used where the detector
+	// configuration does not set them
+	ScoreThreshold  float32            `json:"score_threshold"`
+	NMSThreshold    float32            `json:"nms_threshold"`
+	ClassThresholds map[string]float32 `json:"class_thresholds"`
+
+	decoder Decoder
+}
+
+// Normalization describes how the pixel values are fed to the network
+type Normalization struct {
+	// Mean of each channel subtracted from the pixel values, before they are
+	// multiplied with the scale, 1/255 if it is not set
+	Mean  []float64 `json:"mean"`
+	Scale float64   `json:"scale"`
+	// Whether the model expects RGB instead of BGR input
+	SwapRB bool `json:"swap_rb"`
+}
+
+// LoadManifest reads a manifest file and completes it
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest [%s]: %v", path, err)
+	}
+	dir := filepath.Dir(path)
+	if m.Model != "" && !filepath.IsAbs(m.Model) {
+		m.Model = filepath.Join(dir, m.Model)
+	}
+	if m.LabelFile != "" && !filepath.IsAbs(m.LabelFile) {
+		m.LabelFile = filepath.Join(dir, m.LabelFile)
+	}
+	if err := m.Complete(); err != nil {
+		return nil, fmt.Errorf("invalid manifest [%s]: %v", path, err)
+	}
+	return m, nil
+}
+
+// Complete validates the manifest, loads its label file and fills in the
+// defaults
+func (m *Manifest) Complete() error {
+	if m.Model == "" {
+		return fmt.Errorf("model path is not set")
+	}
+	decoder, err := NewDecoder(m.Family)
+	if err != nil {
+		return err
+	}
+	m.decoder = decoder
+	if m.Family == "" {
+		m.Family = FamilyYOLOv8
+	}
+	if m.InputWidth <= 0 || m.InputHeight <= 0 {
+		m.InputWidth, m.InputHeight = 640, 640
+	}
+	if len(m.Normalization.Mean) != 0 && len(m.Normalization.Mean) != 3 {
+		return fmt.Errorf("mean must have 3 values, got [%d]", len(m.Normalization.Mean))
+	}
+	if m.ScoreThreshold < 0 || m.ScoreThreshold > 1 || m.NMSThreshold < 0 || m.NMSThreshold > 1 {
+		return fmt.Errorf("thresholds must be between 0 and 1")
+	}
+	if m.Normalization.Scale <= 0 {
+		m.Normalization.Scale = 1.0 / 255.0
+	}
+	if m.Name == "" {
+		m.Name = filepath.Base(m.Model)
+	}
+	if len(m.Labels) == 0 && m.LabelFile != "" {
+		labels, err := LoadLabels(m.LabelFile)
+		if err != nil {
+			return err
+		}
+		m.Labels = labels
+	}
+	return nil
+}
+
+// blobParams returns the parameters turning an image into the network input
+func (m *Manifest) blobParams() gocv.ImageToBlobParams {
+	mean := gocv.NewScalar(0, 0, 0, 0)
+	if n := m.Normalization.Mean; len(n) == 3 {
+		mean = gocv.NewScalar(n[0], n[1], n[2], 0)
+	}
+	return gocv.NewImageToBlobParams(m.Normalization.Scale, image.Pt(m.InputWidth, m.InputHeight), mean,
+		m.Normalization.SwapRB, gocv.MatTypeCV32F, gocv.DataLayoutNCHW, gocv.PaddingModeLetterbox, padValue)
+}
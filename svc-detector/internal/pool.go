@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Model holds a loaded network together with its output layer names and the
+// manifest it was loaded from
+type Model struct {
+	Net         gocv.Net
+	OutputNames []string
+	Manifest    *Manifest
+	// pool the model belongs to and is returned to
+	pool *ModelPool
+}
+
+// errPoolRetired is returned by Get once a pool has been replaced
+var errPoolRetired = errors.New("model pool retired")
+
+// ModelPool keeps a fixed number of loaded networks that can be borrowed by
+// concurrent requests. A gocv.Net is not safe for concurrent use, so each
+// network is handed out to a single caller at a time.
+type ModelPool struct {
+	models   chan *Model
+	size     int
+	retired  chan struct{}
+	manifest *Manifest
+	loadedAt time.Time
+}
+
+// NewModelPool loads and warms up size copies of the model in the manifest
+func NewModelPool(man *Manifest, size int) (*ModelPool, error) {
+	if size <= 0 {
+		size = 1
+	}
+	p := &ModelPool{
+		models:   make(chan *Model, size),
+		size:     size,
+		retired:  make(chan struct{}),
+		manifest: man,
+	}
+	for i := 0; i < size; i++ {
+		m, err := loadModel(man)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		m.warmUp()
+		m.pool = p
+		p.models <- m
+		log.Printf("Model [%s] [%d/%d] loaded from [%s]", man.Name, i+1, size, man.Model)
+	}
+	p.loadedAt = time.Now()
+	return p, nil
+}
+
+// Get borrows a model from the pool, waiting until one is available, the
+// context is done or the pool is retired
+func (p *ModelPool) Get(ctx context.Context) (*Model, error) {
+	select {
+	case m := <-p.models:
+		return m, nil
+	case <-p.retired:
+		return nil, errPoolRetired
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Put returns a borrowed model to the pool
+func (p *ModelPool) Put(m *Model) {
+	if m == nil {
+		return
+	}
+	p.models <- m
+}
+
+// Size returns the number of models managed by the pool
+func (p *ModelPool) Size() int {
+	return p.size
+}
+
+// Close releases the models that are currently in the pool
+func (p *ModelPool) Close() {
+	for {
+		select {
+		case m := <-p.models:
+			m.Net.Close()
+		default:
+			return
+		}
+	}
+}
+
+// Retire stops lending the models and closes each of them once it has been
+// returned, the models borrowed before finish their frames
+func (p *ModelPool) Retire() {
+	close(p.retired)
+	go func() {
+		for i := 0; i < p.size; i++ {
+			m := <-p.models
+			m.Net.Close()
+		}
+	}()
+}
+
+// loadModel reads the network from disk and resolves its output layers
+func loadModel(man *Manifest) (*Model, error) {
+	net := gocv.ReadNetFromONNX(man.Model)
+	if net.Empty() {
+		return nil, fmt.Errorf("error reading network model from: %s", man.Model)
+	}
+	net.SetPreferableBackend(gocv.NetBackendDefault)
+	net.SetPreferableTarget(gocv.NetTargetCPU)
+
+	outputNames := getOutputNames(&net)
+	if len(outputNames) == 0 {
+		net.Close()
+		return nil, fmt.Errorf("error reading output layer names")
+	}
+	return &Model{Net: net, OutputNames: outputNames, Manifest: man}, nil
+}
+
+// warmUp runs a single forward pass on a blank image so the first real
+// frame does not pay for the lazy initialization of the network
+func (m *Model) warmUp() {
+	img := gocv.NewMatWithSize(m.Manifest.InputHeight, m.Manifest.InputWidth, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	params := m.Manifest.blobParams()
+	blob := gocv.BlobFromImageWithParams(img, params)
+	defer blob.Close()
+
+	m.Net.SetInput(blob, "")
+	probs := m.Net.ForwardLayers(m.OutputNames)
+	for _, prob := range probs {
+		prob.Close()
+	}
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metric "github.com/etesami/detection-tracking-system/pkg/metric"
+)
+
+// defaultWatchInterval is how often the manifest file is checked for changes
+const defaultWatchInterval = 2 * time.Second
+
+// errModelsClosed is returned by Get once the model manager is closed
+var errModelsClosed = errors.New("models are closed")
+
+// ModelManager lends the models of the active pool and replaces the pool when
+// the manifest is reloaded. The new models are loaded and warmed up before the
+// swap, frames holding a model of the old pool finish on it and the old models
+// are closed once they have all been returned.
+type ModelManager struct {
+	path   string
+	size   int
+	metric *metric.Metric
+
+	pool   atomic.Pointer[ModelPool]
+	closed atomic.Bool
+	// serializes the reloads and Close
+	mu sync.Mutex
+	// modification time and size of the manifest file last loaded
+	modTime time.Time
+	fileLen int64
+}
+
+// NewModelManager loads the pool of the manifest, path is the manifest file
+// reloads read again and may be empty if the manifest was not read from a file
+func NewModelManager(path string, man *Manifest, size int, m *metric.Metric) (*ModelManager, error) {
+	mm := &ModelManager{path: path, size: size, metric: m}
+	if path != "" {
+		if fi, err := os.Stat(path); err == nil {
+			mm.modTime, mm.fileLen = fi.ModTime(), fi.Size()
+		}
+	}
+	pool, err := NewModelPool(man, size)
+	if err != nil {
+		return nil, err
+	}
+	mm.activate(pool)
+	return mm, nil
+}
+
+// activate makes the pool the active one and returns the pool it replaced
+func (mm *ModelManager) activate(pool *ModelPool) *ModelPool {
+	old := mm.pool.Swap(pool)
+	mm.metric.SetActiveModel(pool.manifest.Name, pool.manifest.Version, pool.manifest.Family)
+	return old
+}
+
+// Get borrows a model of the active pool, a pool retired while waiting is
+// replaced by the active one. It fails once the manager is closed.
+func (mm *ModelManager) Get(ctx context.Context) (*Model, error) {
+	for !mm.closed.Load() {
+		m, err := mm.pool.Load().Get(ctx)
+		if err != errPoolRetired {
+			return m, err
+		}
+	}
+	return nil, errModelsClosed
+}
+
+// Put returns a borrowed model to the pool it was borrowed from
+func (mm *ModelManager) Put(m *Model) {
+	if m == nil {
+		return
+	}
+	m.pool.Put(m)
+}
+
+// Manifest returns the manifest of the active models
+func (mm *ModelManager) Manifest() *Manifest {
+	return mm.pool.Load().manifest
+}
+
+// LoadedAt returns when the active models were loaded
+func (mm *ModelManager) LoadedAt() time.Time {
+	return mm.pool.Load().loadedAt
+}
+
+// Reload reads the manifest file again, loads its models and swaps them with
+// the active ones. The active models are kept if the manifest or the models
+// fail to load.
+func (mm *ModelManager) Reload() (*Manifest, error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if mm.closed.Load() {
+		return nil, errModelsClosed
+	}
+	if mm.path == "" {
+		mm.metric.AddModelReload("error")
+		return nil, fmt.Errorf("the model was not loaded from a manifest file")
+	}
+	if fi, err := os.Stat(mm.path); err == nil {
+		mm.modTime, mm.fileLen = fi.ModTime(), fi.Size()
+	}
+	man, err := LoadManifest(mm.path)
+	if err != nil {
+		mm.metric.AddModelReload("error")
+		return nil, err
+	}
+	pool, err := NewModelPool(man, mm.size)
+	if err != nil {
+		mm.metric.AddModelReload("error")
+		return nil, err
+	}
+	mm.activate(pool).Retire()
+	mm.metric.AddModelReload("ok")
+	log.Printf("Model reloaded: [%s] version [%s], family [%s]", man.Name, man.Version, man.Family)
+	return man, nil
+}
+
+// Watch reloads the models whenever the manifest file changes until the
+// context is done
+func (mm *ModelManager) Watch(ctx context.Context, interval time.Duration) {
+	if mm.path == "" {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !mm.changed() {
+			continue
+		}
+		log.Printf("Manifest [%s] changed, reloading the model", mm.path)
+		if _, err := mm.Reload(); err != nil {
+			log.Printf("Error reloading model: %v", err)
+		}
+	}
+}
+
+// changed reports whether the manifest file was modified since it was last
+// loaded
+func (mm *ModelManager) changed() bool {
+	fi, err := os.Stat(mm.path)
+	if err != nil {
+		return false
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return !fi.ModTime().Equal(mm.modTime) || fi.Size() != mm.fileLen
+}
+
+// Close retires the active models, models are no longer lent afterwards
+func (mm *ModelManager) Close() {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if mm.closed.Swap(true) {
+		return
+	}
+	mm.pool.Load().Retire()
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+)
+
+// Merge modes of the detections of the tiles and the full frame
+const (
+	MergeNMS = "nms"
+	MergeWBF = "wbf"
+)
+
+// defaultTileOverlap is the overlap of neighbouring tiles if none is set
+const defaultTileOverlap = 0.2
+
+// Tiling configures the sliced inference, the network runs over overlapping
+// tiles of the frame in addition to the full frame
+type Tiling struct {
+	Enabled bool
+	// Size of the tiles, the input size of the model if not set
+	TileWidth  int
+	TileHeight int
+	// Overlap of neighbouring tiles as a fraction of the tile size
+	Overlap float64
+	// MergeNMS or MergeWBF
+	Merge string
+}
+
+// ParseMergeMode checks the merge mode, NMS is used if it is empty
+func ParseMergeMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return MergeNMS, nil
+	case MergeNMS, MergeWBF:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown merge mode: %s", mode)
+	}
+}
+
+// forSource returns the tiling of a source, the settings sent with its frames
+// replace the detector default and the default merge mode is kept if the
+// source sets none
+func (t Tiling) forSource(tc *pb.TilingConfig) Tiling {
+	if tc == nil {
+		return t
+	}
+	merge := t.Merge
+	if m, err := ParseMergeMode(tc.Merge); err == nil && tc.Merge != "" {
+		merge = m
+	}
+	return Tiling{
+		Enabled:    tc.Enabled,
+		TileWidth:  int(tc.TileWidth),
+		TileHeight: int(tc.TileHeight),
+		Overlap:    tc.Overlap,
+		Merge:      merge,
+	}
+}
+
+// tiles returns the overlapping tiles covering an image of the given size,
+// none if tiling is disabled or the image fits in a single tile
+func (t Tiling) tiles(width, height int, man *Manifest) []image.Rectangle {
+	if !t.Enabled {
+		return nil
+	}
+	tw, th := t.TileWidth, t.TileHeight
+	if tw <= 0 || th <= 0 {
+		tw, th = man.InputWidth, man.InputHeight
+	}
+	if width <= tw && height <= th {
+		return nil
+	}
+	overlap := t.Overlap
+	if overlap <= 0 || overlap >= 1 {
+		overlap = defaultTileOverlap
+	}
+
+	xs := tileOffsets(width, tw, overlap)
+	ys := tileOffsets(height, th, overlap)
+	tiles := make([]image.Rectangle, 0, len(xs)*len(ys))
+	for _, y := range ys {
+		for _, x := range xs {
+			tiles = append(tiles, image.Rect(x, y, min(x+tw, width), min(y+th, height)))
+		}
+	}
+	return tiles
+}
+
+// tileOffsets returns the offsets of the tiles along one side of the image,
+// the last tile is aligned to the end of the side
+func tileOffsets(length, tile int, overlap float64) []int {
+	if length <= tile {
+		return []int{0}
+	}
+	stride := max(int(float64(tile)*(1-overlap)), 1)
+	var offsets []int
+	for o := 0; o+tile < length; o += stride {
+		offsets = append(offsets, o)
+	}
+	return append(offsets, length-tile)
+}
+
+// fuseBoxes merges the overlapping boxes of the same class with weighted box
+// fusion. Boxes are clustered by decreasing confidence, a box joins the first
+// cluster whose fused box it overlaps by more than iouThreshold. The fused box
+// is the confidence-weighted mean of the cluster and its confidence the mean
+// confidence.
+func fuseBoxes(boxes []image.Rectangle, confidences []float32, classIds []int, iouThreshold float32) ([]image.Rectangle, []float32, []int) {
+	type cluster struct {
+		classId int
+		fused   image.Rectangle
+		// confidence-weighted sums of the corners
+		x1, y1, x2, y2 float32
+		weight         float32
+		count          int
+	}
+
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return confidences[order[a]] > confidences[order[b]] })
+
+	var clusters []*cluster
+	for _, i := range order {
+		var joined *cluster
+		for _, c := range clusters {
+			if c.classId == classIds[i] && iou(c.fused, boxes[i]) > iouThreshold {
+				joined = c
+				break
+			}
+		}
+		if joined == nil {
+			joined = &cluster{classId: classIds[i]}
+			clusters = append(clusters, joined)
+		}
+		b, w := boxes[i], confidences[i]
+		joined.x1 += float32(b.Min.X) * w
+		joined.y1 += float32(b.Min.Y) * w
+		joined.x2 += float32(b.Max.X) * w
+		joined.y2 += float32(b.Max.Y) * w
+		joined.weight += w
+		joined.count++
+		if joined.weight > 0 {
+			joined.fused = image.Rect(int(joined.x1/joined.weight), int(joined.y1/joined.weight),
+				int(joined.x2/joined.weight), int(joined.y2/joined.weight))
+		} else {
+			joined.fused = b
+		}
+	}
+
+	fusedBoxes := make([]image.Rectangle, len(clusters))
+	fusedConfidences := make([]float32, len(clusters))
+	fusedIds := make([]int, len(clusters))
+	for k, c := range clusters {
+		fusedBoxes[k] = c.fused
+		fusedConfidences[k] = c.weight / float32(c.count)
+		fusedIds[k] = c.classId
+	}
+	return fusedBoxes, fusedConfidences, fusedIds
+}
+
+// iou returns the intersection over union of two boxes
+func iou(a, b image.Rectangle) float32 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+	interArea := inter.Dx() * inter.Dy()
+	union := a.Dx()*a.Dy() + b.Dx()*b.Dy() - interArea
+	if union <= 0 {
+		return 0
+	}
+	return float32(interArea) / float32(union)
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"image"
+	"slices"
+	"testing"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+)
+
+func TestTilingForSource(t *testing.T) {
+	def := Tiling{Enabled: true, TileWidth: 320, TileHeight: 320, Overlap: 0.1, Merge: MergeWBF}
+	tests := []struct {
+		name string
+		tc   *pb.TilingConfig
+		want Tiling
+	}{
+		{"default", nil, def},
+		{"disabled", &pb.TilingConfig{}, Tiling{Merge: MergeWBF}},
+		{"merge kept", &pb.TilingConfig{Enabled: true, TileWidth: 640, TileHeight: 480}, Tiling{Enabled: true, TileWidth: 640, TileHeight: 480, Merge: MergeWBF}},
+		{"merge set", &pb.TilingConfig{Enabled: true, Merge: MergeNMS}, Tiling{Enabled: true, Merge: MergeNMS}},
+		{"unknown merge", &pb.TilingConfig{Enabled: true, Merge: "max"}, Tiling{Enabled: true, Merge: MergeWBF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := def.forSource(tt.tc); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTilingTiles(t *testing.T) {
+	man := &Manifest{InputWidth: 640, InputHeight: 640}
+	tests := []struct {
+		name          string
+		tiling        Tiling
+		width, height int
+		want          []image.Rectangle
+	}{
+		{"disabled", Tiling{}, 1920, 1080, nil},
+		{"fits in one tile", Tiling{Enabled: true}, 640, 360, nil},
+		{
+			name:   "overlapping tiles",
+			tiling: Tiling{Enabled: true, TileWidth: 400, TileHeight: 300, Overlap: 0.5},
+			width:  800,
+			height: 300,
+			want: []image.Rectangle{
+				image.Rect(0, 0, 400, 300),
+				image.Rect(200, 0, 600, 300),
+				image.Rect(400, 0, 800, 300),
+			},
+		},
+		{
+			name:   "last tile aligned to the end",
+			tiling: Tiling{Enabled: true, Overlap: 0.2},
+			width:  1000,
+			height: 640,
+			want: []image.Rectangle{
+				image.Rect(0, 0, 640, 640),
+				image.Rect(360, 0, 1000, 640),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tiling.tiles(tt.width, tt.height, man); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"errors"
+)
+
+// StatusBusy is the ack status of a frame rejected since the detector is
+// overloaded
+const StatusBusy = "busy"
+
+// ErrBusy is returned when the queue of a worker pool is full
+var ErrBusy = errors.New("detector is busy")
+
+// WorkerPool runs jobs on a fixed number of goroutines. Jobs wait in a
+// bounded queue for a free worker and are rejected when the queue is full, so
+// an overloaded detector answers right away instead of piling up requests.
+type WorkerPool struct {
+	jobs chan func()
+}
+
+// NewWorkerPool starts the workers, at most queueSize jobs wait for them
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &WorkerPool{jobs: make(chan func(), queueSize)}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	for job := range p.jobs {
+		job()
+	}
+}
+
+// Do queues the job and waits until it has run, it returns ErrBusy without
+// waiting if the queue is full. A job whose context is done before a worker
+// picks it up is skipped and the context error is returned.
+func (p *WorkerPool) Do(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	ran := false
+	job := func() {
+		defer close(done)
+		if ctx.Err() != nil {
+			return
+		}
+		fn()
+		ran = true
+	}
+	select {
+	case p.jobs <- job:
+	default:
+		return ErrBusy
+	}
+	// the job may refer to the data of the caller, so it is waited for even
+	// if the context is done
+	<-done
+	if !ran {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Queued returns the number of jobs waiting for a worker
+func (p *WorkerPool) Queued() int {
+	return len(p.jobs)
+}
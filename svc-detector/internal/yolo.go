@@ -1,5 +1,12 @@
 package internal
 
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
 // Array of YOLOv8 class labels
 var classes = []string{
 	"person", "bicycle", "car", "motorcycle", "airplane", "bus", "train", "truck", "boat",
@@ -12,3 +19,48 @@ var classes = []string{
 	"remote", "keyboard", "cell phone", "microwave", "oven", "toaster", "sink", "refrigerator", "book",
 	"clock", "vase", "scissors", "teddy bear", "hair drier", "toothbrush",
 }
+
+// LoadLabels reads the class labels from a file with one label per line,
+// the line number is the class id
+func LoadLabels(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open label file: %v", err)
+	}
+	defer f.Close()
+
+	var labels []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		labels = append(labels, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read label file: %v", err)
+	}
+	// trailing empty lines do not name a class
+	for len(labels) > 0 && labels[len(labels)-1] == "" {
+		labels = labels[:len(labels)-1]
+	}
+	return labels, nil
+}
+
+// labels returns the class labels of the model or the COCO classes
+func (m *Manifest) labels() []string {
+	if len(m.Labels) > 0 {
+		return m.Labels
+	}
+	return classes
+}
+
+// label returns the label of a class id
+func (m *Manifest) label(classId int) string {
+	return labelOf(m.labels(), classId)
+}
+
+// labelOf returns the label of a class id, unknown ids are named by their id
+func labelOf(labels []string, classId int) string {
+	if classId < 0 || classId >= len(labels) || labels[classId] == "" {
+		return fmt.Sprintf("class_%d", classId)
+	}
+	return labels[classId]
+}
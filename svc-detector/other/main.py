@@ -3,4 +3,6 @@ from ultralytics import YOLO
 model = YOLO("yolov8n.pt") 
 model.export(format="onnx")
 
-# model.export(format="onnx", imgsz=[360,640])
\ No newline at end of file
+# model.export(format="onnx", imgsz=[360,640])
+# A dynamic batch size is needed for BATCH_SIZE > 1
+# model.export(format="onnx", dynamic=True)
\ No newline at end of file
@@ -5,11 +5,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	api "github.com/etesami/detection-tracking-system/api"
 	metric "github.com/etesami/detection-tracking-system/pkg/metric"
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
 	utils "github.com/etesami/detection-tracking-system/pkg/utils"
 
 	"github.com/bluenviron/gortsplib/v4"
@@ -65,11 +68,35 @@ func main() {
 		Port:    REMOTE_SVC_PORT,
 	}
 	var client utils.GrpcClient
+
+	// Optional settings the aggregator should use for this source
+	profileWidth := envInt("PROFILE_IMAGE_WIDTH")
+	profileHeight := envInt("PROFILE_IMAGE_HEIGHT")
+	profileFrameRate := envFloat("PROFILE_FRAME_RATE")
+	profileDetectionFrq := envInt("PROFILE_DETECTION_FREQUENCY")
+	profileQueueSize := envInt("PROFILE_QUEUE_SIZE")
+	profileMaxFrames := envInt("PROFILE_MAX_TOTAL_FRAMES")
+	source := &pb.RegisterSourceRequest{
+		Name:      os.Getenv("SOURCE_NAME"),
+		Transport: os.Getenv("SOURCE_TRANSPORT"),
+		Codec:     "h264",
+		Labels:    utils.ParseLabels(os.Getenv("SOURCE_LABELS")),
+		Profile: &pb.SourceProfile{
+			ImageWidth:         int32(profileWidth),
+			ImageHeight:        int32(profileHeight),
+			FrameRate:          profileFrameRate,
+			DetectionFrequency: int32(profileDetectionFrq),
+			QueueSize:          int32(profileQueueSize),
+			MaxTotalFrames:     int32(profileMaxFrames),
+			Username:           os.Getenv("PROFILE_USERNAME"),
+			Password:           os.Getenv("PROFILE_PASSWORD"),
+		},
+	}
 	go utils.MonitorConnection1(targetSvc, &client)
 
 	// First call to processTicker
 	time.Sleep(2 * time.Second) // Wait a few seconds before the first call to let connection be established
-	if err := internal.ProcessTicker(&client, "aggregator", m, RTSP_SERVER_PORT); err != nil {
+	if err := internal.ProcessTicker(&client, "aggregator", m, RTSP_SERVER_PORT, source); err != nil {
 		log.Printf("Error during processing: %v", err)
 	}
 
@@ -78,10 +105,18 @@ func main() {
 	defer ticker.Stop()
 
 	log.Printf("Update frequency: %d seconds\n", updateFrequency)
+	stopTicker := make(chan struct{})
+	tickerDone := make(chan struct{})
 	go func(m *metric.Metric, c *utils.GrpcClient) {
-		for range ticker.C {
-			if err := internal.ProcessTicker(c, "aggregator", m, RTSP_SERVER_PORT); err != nil {
-				log.Printf("Error during processing: %v", err)
+		defer close(tickerDone)
+		for {
+			select {
+			case <-stopTicker:
+				return
+			case <-ticker.C:
+				if err := internal.ProcessTicker(c, "aggregator", m, RTSP_SERVER_PORT, source); err != nil {
+					log.Printf("Error during processing: %v", err)
+				}
 			}
 		}
 	}(m, &client)
@@ -89,8 +124,56 @@ func main() {
 	metricAddr := os.Getenv("METRIC_ADDR")
 	metricPort := os.Getenv("METRIC_PORT")
 	http.Handle("/metrics", promhttp.Handler())
-	log.Printf("Starting server on :%s\n", metricPort)
-	http.ListenAndServe(fmt.Sprintf("%s:%s", metricAddr, metricPort), nil)
+	go func() {
+		log.Printf("Starting server on :%s\n", metricPort)
+		if err := http.ListenAndServe(fmt.Sprintf("%s:%s", metricAddr, metricPort), nil); err != nil {
+			log.Fatalf("ListenAndServe(): %v", err)
+		}
+	}()
+
+	// Set up channel to listen for interrupt or terminate signals
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	<-sigChan // Wait for signal
+	log.Printf("Received shutdown signal\n")
+	ticker.Stop()
+	// Wait for a registration in progress so it does not renew the lease
+	// after the source is unregistered
+	close(stopTicker)
+	<-tickerDone
+	// Let the aggregator stop reading the stream instead of waiting for the lease to expire
+	if err := internal.Unregister(&client); err != nil {
+		log.Printf("Error during unregistering: %v", err)
+	}
+	log.Printf("Server shut down gracefully\n")
+}
+
+// envInt returns the integer value of an environment variable, zero if it is
+// not set, and exits if it is set to something else than a number
+func envInt(name string) int {
+	str := os.Getenv(name)
+	if str == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(str)
+	if err != nil {
+		log.Fatalf("Error parsing %s: %v", name, err)
+	}
+	return v
+}
+
+// envFloat is the floating point counterpart of envInt
+func envFloat(name string) float64 {
+	str := os.Getenv(name)
+	if str == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		log.Fatalf("Error parsing %s: %v", name, err)
+	}
+	return v
 }
 
 // startRTSPStream starts an RTSP server that streams a file in MPEG-TS format.
@@ -9,4 +9,12 @@ export REMOTE_SVC_HOST=localhost
 export REMOTE_SVC_PORT=5002
 
 export METRIC_ADDR=localhost
-export METRIC_PORT=8001
\ No newline at end of file
+export METRIC_PORT=8001
+# Optional per-source settings sent to the aggregator on registration
+export PROFILE_IMAGE_WIDTH=640
+export PROFILE_IMAGE_HEIGHT=360
+export PROFILE_FRAME_RATE=5
+export PROFILE_DETECTION_FREQUENCY=5
+export SOURCE_NAME=""
+export SOURCE_TRANSPORT="tcp"
+export SOURCE_LABELS="site=toronto"
\ No newline at end of file
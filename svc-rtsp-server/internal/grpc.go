@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
+	"sync/atomic"
 	"time"
 
 	metric "github.com/etesami/detection-tracking-system/pkg/metric"
@@ -17,37 +19,76 @@ type Server struct {
 	Metric *metric.Metric
 }
 
-// processTicker processes the ticker event
-func ProcessTicker(clientRef *utils.GrpcClient, serverName string, metricList *metric.Metric, rtspPort string) error {
+// sourceId is the id assigned by the remote service on registration
+var sourceId atomic.Value
+
+// streamURL returns the url the remote service reads the stream from
+func streamURL(rtspPort string) string {
+	ip, err := utils.GetOutboundIP()
+	if err != nil {
+		log.Printf("Error getting outbound IP: %v", err)
+	}
+	return fmt.Sprintf("rtsp://%s/stream", net.JoinHostPort(ip, rtspPort))
+}
+
+// ProcessTicker registers the source with the remote service, renewing its
+// lease, and records the RTT of the call. The call is synchronous so that a
+// registration never races with Unregister at shutdown.
+func ProcessTicker(clientRef *utils.GrpcClient, serverName string, metricList *metric.Metric, rtspPort string, source *pb.RegisterSourceRequest) error {
 
 	client := clientRef.Load()
 	if client == nil {
 		return nil
 	}
 
-	ip, err := utils.GetOutboundIP()
+	ping := &pb.RegisterSourceRequest{
+		Name:          source.Name,
+		StreamUrl:     streamURL(rtspPort),
+		Transport:     source.Transport,
+		Codec:         source.Codec,
+		Labels:        source.Labels,
+		Profile:       source.Profile,
+		SentTimestamp: time.Now().Format(time.RFC3339Nano),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	pong, err := client.RegisterSource(ctx, ping)
 	if err != nil {
-		log.Printf("Error getting outbound IP: %v", err)
+		return fmt.Errorf("error registering source: %v", err)
+	}
+	sourceId.Store(pong.SourceId)
+	rtt, err := utils.CalculateRtt(ping.SentTimestamp, pong.ReceivedTimestamp, pong.AckSentTimestamp, time.Now().Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("error calculating RTT: %v", err)
+	}
+	metricList.AddRttTime(serverName, float64(rtt)/1000.0)
+	log.Printf("Sever response: [%s], source [%s], lease [%d]s, RTT [%.2f] ms\n", pong.Status, pong.SourceId, pong.LeaseSeconds, float64(rtt)/1000.0)
+
+	return nil
+}
+
+// Unregister informs the remote service that this source is shutting down
+func Unregister(clientRef *utils.GrpcClient) error {
+	client := clientRef.Load()
+	if client == nil {
+		return fmt.Errorf("client is not initialized")
+	}
+	id, ok := sourceId.Load().(string)
+	if !ok || id == "" {
+		return fmt.Errorf("source is not registered")
 	}
-	go func(m *metric.Metric) {
-		ping := &pb.Data{
-			Payload:       fmt.Sprintf("%s:%s", ip, rtspPort),
-			SentTimestamp: time.Now().Format(time.RFC3339Nano),
-		}
-		pong, err := client.SendDataToServer(context.Background(), ping)
-		// in case the target service is not reachable anymore we should just return
-		if err != nil {
-			log.Printf("Error sending data to server: %v", err)
-			return
-		}
-		rtt, err := utils.CalculateRtt(ping.SentTimestamp, pong.ReceivedTimestamp, pong.AckSentTimestamp, time.Now().Format(time.RFC3339Nano))
-		if err != nil {
-			log.Printf("Error calculating RTT: %v", err)
-			return
-		}
-		m.AddRttTime(serverName, float64(rtt)/1000.0)
-		log.Printf("Sever response: [%s], RTT [%.2f] ms\n", pong.Status, float64(rtt)/1000.0)
-	}(metricList)
 
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ack, err := client.UnregisterSource(ctx, &pb.Data{
+		Payload:       id,
+		SentTimestamp: time.Now().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return fmt.Errorf("error unregistering source: %v", err)
+	}
+	log.Printf("Unregistered from server: [%s]\n", ack.Status)
 	return nil
 }
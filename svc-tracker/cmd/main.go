@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	api "github.com/etesami/detection-tracking-system/api"
 	metric "github.com/etesami/detection-tracking-system/pkg/metric"
@@ -52,19 +53,77 @@ func main() {
 	height, _ := strconv.Atoi(os.Getenv("IMAGE_HEIGHT"))
 	saveImageFrqTr, _ := strconv.Atoi(os.Getenv("SAVE_IMAGE_FREQUENCY_TRACKING"))
 	saveImageFrqDt, _ := strconv.Atoi(os.Getenv("SAVE_IMAGE_FREQUENCY_DETECTION"))
+	maxMisses, _ := strconv.Atoi(os.Getenv("TRACK_MAX_MISSES"))
+	iouThreshold, _ := strconv.ParseFloat(os.Getenv("TRACK_IOU_THRESHOLD"), 64)
+	reorderWindow, _ := strconv.Atoi(os.Getenv("REORDER_WINDOW"))
+	reorderMaxWait, _ := strconv.Atoi(os.Getenv("REORDER_MAX_WAIT_MS"))
+	latePolicy, err := internal.ParseLatePolicy(os.Getenv("LATE_FRAME_POLICY"))
+	if err != nil {
+		log.Fatalf("Failed to parse late frame policy: %v", err)
+	}
+
+	dtConfig := &internal.DtConfig{
+		Model:                os.Getenv("YOLO_MODEL"),
+		ImageWidth:           width,
+		ImageHeight:          height,
+		SaveImage:            os.Getenv("SAVE_IMAGE") == "true",
+		SaveImagePath:        os.Getenv("SAVE_IMAGE_PATH"),
+		SaveImageFrequencyTr: saveImageFrqTr,
+		SaveImageFrequencyDt: saveImageFrqDt,
+		MaxMisses:            maxMisses,
+		IoUThreshold:         iouThreshold,
+		ReorderWindow:        reorderWindow,
+		ReorderMaxWait:       time.Duration(reorderMaxWait) * time.Millisecond,
+		LateFramePolicy:      latePolicy,
+	}
+
+	strategy, err := internal.NewTracker(os.Getenv("TRACKER_ALGORITHM"), dtConfig)
+	if err != nil {
+		log.Fatalf("Failed to create tracker: %v", err)
+	}
+	log.Printf("Using tracker algorithm [%s]", strategy.Name())
+
+	// The tracking results are always available to SubscribeTracks streams,
+	// file and webhook outputs are enabled by their configuration
+	subscribers := internal.NewStreamSink()
+	sinks := []internal.Sink{subscribers}
+	if path := os.Getenv("RESULTS_JSONL_PATH"); path != "" {
+		jsonlSink, err := internal.NewJSONLSink(path)
+		if err != nil {
+			log.Fatalf("Failed to create results sink: %v", err)
+		}
+		sinks = append(sinks, jsonlSink)
+	}
+	if url := os.Getenv("RESULTS_WEBHOOK_URL"); url != "" {
+		webhookTimeout, _ := strconv.Atoi(os.Getenv("RESULTS_WEBHOOK_TIMEOUT_MS"))
+		if webhookTimeout <= 0 {
+			webhookTimeout = 2000
+		}
+		sinks = append(sinks, internal.NewWebhookSink(url, time.Duration(webhookTimeout)*time.Millisecond))
+	}
+	queueSize, _ := strconv.Atoi(os.Getenv("RESULTS_QUEUE_SIZE"))
+	results := internal.NewResultPublisher(queueSize, sinks...)
+	for _, sink := range sinks {
+		log.Printf("Publishing tracking results to [%s]", sink.Name())
+	}
 
 	s := &internal.Server{
-		DtConfig: &internal.DtConfig{
-			Model:                os.Getenv("YOLO_MODEL"),
-			ImageWidth:           width,
-			ImageHeight:          height,
-			SaveImage:            os.Getenv("SAVE_IMAGE") == "true",
-			SaveImagePath:        os.Getenv("SAVE_IMAGE_PATH"),
-			SaveImageFrequencyTr: saveImageFrqTr,
-			SaveImageFrequencyDt: saveImageFrqDt,
-		},
-		Trackers: make(map[string]*internal.TrackerClient),
+		DtConfig:    dtConfig,
+		Metric:      m,
+		Trackers:    make(map[string]*internal.TrackerClient),
+		Strategy:    strategy,
+		Results:     results,
+		Subscribers: subscribers,
 	}
+
+	// The reorder state of a source that stopped sending frames is removed
+	// after the idle timeout
+	idleTimeout, _ := strconv.Atoi(os.Getenv("SOURCE_IDLE_TIMEOUT_S"))
+	if idleTimeout <= 0 {
+		idleTimeout = 300
+	}
+	go s.PruneSequencers(time.Duration(idleTimeout)*time.Second, time.Duration(idleTimeout)*time.Second/2)
+
 	grpcServer := grpc.NewServer()
 	pb.RegisterDetectionTrackingPipelineServer(grpcServer, s)
 
@@ -79,6 +138,7 @@ func main() {
 	metricPort := os.Getenv("METRIC_PORT")
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/tracks", s.TracksHandler)
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf("%s:%s", metricAddr, metricPort),
@@ -102,6 +162,7 @@ func main() {
 	<-sigChan // Wait for signal
 	log.Printf("Received shutdown signal\n")
 	// cancel()                  // Cancel the context
+	results.Close()           // Flush the results and end the subscriptions
 	grpcServer.GracefulStop() // Stop the gRPC server gracefully
 	if err := server.Shutdown(context.Background()); err != nil {
 		log.Printf("Error shutting down server: %v\n", err)
@@ -9,4 +9,22 @@ export METRIC_PORT=8004
 export SAVE_IMAGE="true"
 export SAVE_IMAGE_PATH="/tmp/imgs/"
 export SAVE_IMAGE_FREQUENCY_TRACKING=1
-export SAVE_IMAGE_FREQUENCY_DETECTION=1
\ No newline at end of file
+export SAVE_IMAGE_FREQUENCY_DETECTION=1
+export TRACK_MAX_MISSES=3
+export TRACKER_ALGORITHM="kcf"
+# Minimum IoU of a detection matched with a track, 0 keeps the defaults of
+# the algorithm: 0.5 for KCF, 0.3 for SORT and 0.2/0.5 for the two ByteTrack
+# associations
+export TRACK_IOU_THRESHOLD=0.5
+# The file grows without rotation, e.g. /tmp/tracks.jsonl
+export RESULTS_JSONL_PATH=""
+export RESULTS_WEBHOOK_URL=""
+export RESULTS_WEBHOOK_TIMEOUT_MS=2000
+export RESULTS_QUEUE_SIZE=256
+# Frames held for reordering per source, the frames beyond four times the
+# window waiting to be applied are dropped
+export REORDER_WINDOW=8
+export REORDER_MAX_WAIT_MS=1000
+export LATE_FRAME_POLICY="drop"
+# Seconds without frames after which the reorder state of a source is removed
+export SOURCE_IDLE_TIMEOUT_S=300
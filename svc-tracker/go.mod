@@ -7,6 +7,7 @@ require (
 	github.com/prometheus/client_golang v1.22.0
 	gocv.io/x/gocv v0.41.0
 	google.golang.org/grpc v1.72.0
+	google.golang.org/protobuf v1.36.6
 )
 
 require (
@@ -20,5 +21,6 @@ require (
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250218202821-56aae31c358a // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
 )
+
+replace github.com/etesami/detection-tracking-system => ../
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"image"
+	"log"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"github.com/etesami/detection-tracking-system/pkg/utils"
+	"gocv.io/x/gocv"
+)
+
+var (
+	// Detections at or above this confidence take part in the first association
+	// and may start new tracks
+	byteHighThreshold float32 = 0.5
+	// Detections below this confidence are ignored
+	byteLowThreshold float32 = 0.1
+
+	// Default gating of the two associations, a configured IoU threshold
+	// replaces both
+	byteHighIoUThreshold = 0.2
+	byteLowIoUThreshold  = 0.5
+)
+
+// byteTracker implements ByteTrack: tracks are first associated with the high
+// confidence detections, the remaining tracks are then associated with the low
+// confidence detections so that occluded objects are not lost. Motion is
+// estimated with the same Kalman filter as SORT.
+type byteTracker struct {
+	config *DtConfig
+}
+
+func (bt *byteTracker) Name() string {
+	return TrackerByteTrack
+}
+
+func (bt *byteTracker) Update(tc *TrackerClient, frame gocv.Mat, frameId int64, detections []*pb.BoundingBox) {
+	sourceName := "Detect"
+
+	predicted := predictTracks(tc.trackerInstance, frameId)
+
+	var high, low []*pb.BoundingBox
+	for _, box := range detections {
+		switch {
+		// boxes without a confidence are treated as confident detections
+		case box.Confidence == 0 || box.Confidence >= byteHighThreshold:
+			high = append(high, box)
+		case box.Confidence >= byteLowThreshold:
+			low = append(low, box)
+		}
+	}
+
+	// First association: all tracks with the high confidence detections
+	matches, unmatchedTracks, unmatchedHigh := matchIoU(predicted, boxesToRects(high), bt.config.iouThreshold(byteHighIoUThreshold), func(i, j int) bool {
+		return sameClass(tc.trackerInstance[i], high[j])
+	})
+	for i, j := range matches {
+		tc.trackerInstance[i].Correct(high[j], frameId)
+	}
+
+	// Second association: the remaining tracks with the low confidence detections
+	remaining := make([]image.Rectangle, len(unmatchedTracks))
+	for k, i := range unmatchedTracks {
+		remaining[k] = predicted[i]
+	}
+	lowMatches, stillUnmatched, _ := matchIoU(remaining, boxesToRects(low), bt.config.iouThreshold(byteLowIoUThreshold), func(k, j int) bool {
+		return sameClass(tc.trackerInstance[unmatchedTracks[k]], low[j])
+	})
+	for k, j := range lowMatches {
+		tc.trackerInstance[unmatchedTracks[k]].Correct(low[j], frameId)
+	}
+	for _, k := range stillUnmatched {
+		tc.trackerInstance[unmatchedTracks[k]].MarkMissed()
+	}
+	log.Printf("Frame [%d], [%s]: Matches high/low: [%d/%d], Unmatched: [%d]",
+		frameId, sourceName, len(matches), len(lowMatches), len(unmatchedHigh))
+
+	removed := tc.RemoveLost(bt.config.MaxMisses)
+
+	// Only high confidence detections start new tracks
+	for _, j := range unmatchedHigh {
+		tc.AddInstance(tc.NewKalmanTrack(high[j], frameId))
+	}
+	log.Printf("Frame [%d], [%s]: Removed tracks: [%d], Active tracks: [%d]", frameId, sourceName, removed, len(tc.trackerInstance))
+}
+
+func (bt *byteTracker) Predict(tc *TrackerClient, frame gocv.Mat, frameId int64) int {
+	ageTracks(tc.trackerInstance, frameId)
+	return 0
+}
+
+func boxesToRects(boxes []*pb.BoundingBox) []image.Rectangle {
+	rects := make([]image.Rectangle, len(boxes))
+	for i, box := range boxes {
+		rects[i] = utils.BoxToRect(box)
+	}
+	return rects
+}
@@ -1,6 +1,13 @@
 package internal
 
-import "image"
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+
+	"gocv.io/x/gocv"
+)
 
 func getIoU(bb1, bb2 image.Rectangle) float64 {
 	intersect := bb1.Intersect(bb2)
@@ -11,3 +18,32 @@ func getIoU(bb1, bb2 image.Rectangle) float64 {
 	unionArea := float64(bb1.Dx()*bb1.Dy() + bb2.Dx()*bb2.Dy() - int(interArea))
 	return interArea / unionArea
 }
+
+type point struct {
+	X, Y float64
+}
+
+// center returns the centre of a rectangle
+func center(r image.Rectangle) point {
+	return point{
+		X: float64(r.Min.X+r.Max.X) / 2,
+		Y: float64(r.Min.Y+r.Max.Y) / 2,
+	}
+}
+
+// drawTracks draws the box and the id of each track on the image
+func drawTracks(img *gocv.Mat, tracks []*TrackerInstance) {
+	for _, ti := range tracks {
+		gocv.Rectangle(img, ti.store, color.RGBA{0, 255, 0, 0}, 2)
+		text := fmt.Sprintf("#%d %s", ti.id, ti.label)
+		gocv.PutText(img, text, image.Point{ti.store.Min.X, ti.store.Min.Y - 5}, gocv.FontHersheyPlain, 0.8, color.RGBA{0, 255, 0, 0}, 1)
+	}
+}
+
+// logTracks prints one line per active track
+func logTracks(frameId int64, sourceName string, tracks []*TrackerInstance) {
+	for _, ti := range tracks {
+		log.Printf("Frame [%d], [%s]: Track [%d] class [%s] box [%v] first/last seen [%d/%d] hits [%d] misses [%d] age [%d]",
+			frameId, sourceName, ti.id, ti.label, ti.store, ti.firstSeen, ti.lastSeen, ti.hits, ti.misses, ti.age)
+	}
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"image"
+	"math"
+)
+
+// hungarian solves the rectangular assignment problem for the given cost
+// matrix and returns, for every row, the index of the assigned column or -1
+func hungarian(cost [][]float64) []int {
+	n := len(cost)
+	if n == 0 {
+		return nil
+	}
+	m := len(cost[0])
+	res := make([]int, n)
+	for i := range res {
+		res[i] = -1
+	}
+	if m == 0 {
+		return res
+	}
+
+	// The algorithm below requires at least as many columns as rows
+	if n > m {
+		t := make([][]float64, m)
+		for j := range t {
+			t[j] = make([]float64, n)
+			for i := range cost {
+				t[j][i] = cost[i][j]
+			}
+		}
+		for j, i := range hungarian(t) {
+			if i >= 0 {
+				res[i] = j
+			}
+		}
+		return res
+	}
+
+	// Potentials based O(n^2 m) implementation, indices are 1-based and
+	// column 0 is a virtual column used as the starting point of each row
+	u := make([]float64, n+1)
+	v := make([]float64, m+1)
+	p := make([]int, m+1)
+	way := make([]int, m+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, m+1)
+		used := make([]bool, m+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				cur := cost[i0-1][j-1] - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+	for j := 1; j <= m; j++ {
+		if p[j] != 0 {
+			res[p[j]-1] = j - 1
+		}
+	}
+	return res
+}
+
+// matchIoU assigns detections to tracks maximising the total IoU. Pairs with
+// an IoU below threshold, or that compatible rejects, are reported as
+// unmatched. A nil compatible accepts all pairs.
+func matchIoU(tracks, detections []image.Rectangle, threshold float64, compatible func(i, j int) bool) (matches map[int]int, unmatchedTracks, unmatchedDets []int) {
+	matches = make(map[int]int, len(tracks))
+	cost := make([][]float64, len(tracks))
+	for i, tr := range tracks {
+		cost[i] = make([]float64, len(detections))
+		for j, det := range detections {
+			cost[i][j] = 1 - getIoU(tr, det)
+			// incompatible pairs never pass the threshold
+			if compatible != nil && !compatible(i, j) {
+				cost[i][j] = 1
+			}
+		}
+	}
+
+	matchedDets := make([]bool, len(detections))
+	for i, j := range hungarian(cost) {
+		if j < 0 || 1-cost[i][j] < threshold {
+			unmatchedTracks = append(unmatchedTracks, i)
+			continue
+		}
+		matches[i] = j
+		matchedDets[j] = true
+	}
+	for j, ok := range matchedDets {
+		if !ok {
+			unmatchedDets = append(unmatchedDets, j)
+		}
+	}
+	return matches, unmatchedTracks, unmatchedDets
+}
@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"image"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestHungarian(t *testing.T) {
+	tests := []struct {
+		name string
+		cost [][]float64
+		// minimum total cost and number of assigned rows
+		want     float64
+		assigned int
+	}{
+		{
+			name:     "square",
+			cost:     [][]float64{{4, 1, 3}, {2, 0, 5}, {3, 2, 2}},
+			want:     5,
+			assigned: 3,
+		},
+		{
+			name:     "more rows than columns",
+			cost:     [][]float64{{1, 9}, {9, 1}, {5, 5}},
+			want:     2,
+			assigned: 2,
+		},
+		{
+			name:     "more columns than rows",
+			cost:     [][]float64{{9, 1, 5}, {1, 9, 5}},
+			want:     2,
+			assigned: 2,
+		},
+		{
+			name:     "greedy choice is not optimal",
+			cost:     [][]float64{{1, 2}, {2, 10}},
+			want:     4,
+			assigned: 2,
+		},
+		{
+			name:     "ties",
+			cost:     [][]float64{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}},
+			want:     3,
+			assigned: 3,
+		},
+		{
+			name:     "no columns",
+			cost:     [][]float64{{}, {}},
+			assigned: 0,
+		},
+		{
+			name:     "no rows",
+			cost:     nil,
+			assigned: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := hungarian(tt.cost)
+			if len(res) != len(tt.cost) {
+				t.Fatalf("got [%d] rows, want [%d]", len(res), len(tt.cost))
+			}
+			used := make(map[int]bool)
+			total, assigned := 0.0, 0
+			for i, j := range res {
+				if j < 0 {
+					continue
+				}
+				if used[j] {
+					t.Fatalf("column [%d] assigned twice in %v", j, res)
+				}
+				used[j] = true
+				total += tt.cost[i][j]
+				assigned++
+			}
+			if assigned != tt.assigned {
+				t.Errorf("assigned [%d] rows, want [%d]: %v", assigned, tt.assigned, res)
+			}
+			if total != tt.want {
+				t.Errorf("total cost [%v], want [%v]: %v", total, tt.want, res)
+			}
+		})
+	}
+}
+
+// box returns a 10x10 box shifted by dx, the IoU of two such boxes is 0.82,
+// 0.67, 0.54, 0.33 and 0.25 for a shift of 1, 2, 3, 5 and 6 pixels
+func box(dx int) image.Rectangle {
+	return image.Rect(dx, 0, dx+10, 10)
+}
+
+func TestMatchIoU(t *testing.T) {
+	tests := []struct {
+		name       string
+		tracks     []image.Rectangle
+		detections []image.Rectangle
+		threshold  float64
+		compatible func(i, j int) bool
+		want       map[int]int
+		wantTracks []int
+		wantDets   []int
+	}{
+		{
+			name:       "more tracks than detections",
+			tracks:     []image.Rectangle{box(0), box(100), box(200)},
+			detections: []image.Rectangle{box(201), box(1)},
+			threshold:  0.3,
+			want:       map[int]int{0: 1, 2: 0},
+			wantTracks: []int{1},
+		},
+		{
+			name:       "more detections than tracks",
+			tracks:     []image.Rectangle{box(100)},
+			detections: []image.Rectangle{box(0), box(102), box(200)},
+			threshold:  0.3,
+			want:       map[int]int{0: 1},
+			wantDets:   []int{0, 2},
+		},
+		{
+			name:       "pairs below the threshold are not matched",
+			tracks:     []image.Rectangle{box(0), box(100)},
+			detections: []image.Rectangle{box(5), box(103)},
+			threshold:  0.5,
+			want:       map[int]int{1: 1},
+			wantTracks: []int{0},
+			wantDets:   []int{0},
+		},
+		{
+			name:       "pair equal to the threshold is matched",
+			tracks:     []image.Rectangle{box(0)},
+			detections: []image.Rectangle{image.Rect(0, 0, 10, 5)},
+			threshold:  0.5,
+			want:       map[int]int{0: 0},
+		},
+		{
+			// the greedy choice pairs the first track with the first
+			// detection and leaves the second track below the threshold
+			name:       "total IoU is maximised",
+			tracks:     []image.Rectangle{box(0), box(4)},
+			detections: []image.Rectangle{box(1), box(-2)},
+			threshold:  0.3,
+			want:       map[int]int{0: 1, 1: 0},
+		},
+		{
+			name:       "incompatible pairs are not matched",
+			tracks:     []image.Rectangle{box(0), box(100)},
+			detections: []image.Rectangle{box(1), box(101)},
+			threshold:  0.3,
+			compatible: func(i, j int) bool { return i != 0 },
+			want:       map[int]int{1: 1},
+			wantTracks: []int{0},
+			wantDets:   []int{0},
+		},
+		{
+			name:       "incompatible best pair falls back to a compatible one",
+			tracks:     []image.Rectangle{box(0)},
+			detections: []image.Rectangle{box(1), box(2)},
+			threshold:  0.3,
+			compatible: func(i, j int) bool { return j != 0 },
+			want:       map[int]int{0: 1},
+			wantDets:   []int{0},
+		},
+		{
+			name:       "ties are matched one to one",
+			tracks:     []image.Rectangle{box(0), box(0)},
+			detections: []image.Rectangle{box(1), box(1)},
+			threshold:  0.3,
+		},
+		{
+			name:       "no detections",
+			tracks:     []image.Rectangle{box(0), box(100)},
+			threshold:  0.3,
+			want:       map[int]int{},
+			wantTracks: []int{0, 1},
+		},
+		{
+			name:       "no tracks",
+			detections: []image.Rectangle{box(0)},
+			threshold:  0.3,
+			want:       map[int]int{},
+			wantDets:   []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, unmatchedTracks, unmatchedDets := matchIoU(tt.tracks, tt.detections, tt.threshold, tt.compatible)
+			if tt.want == nil {
+				// any one to one assignment of the tied pairs is valid
+				dets := slices.Sorted(maps.Values(matches))
+				if len(matches) != len(tt.tracks) || !slices.Equal(dets, []int{0, 1}) {
+					t.Errorf("matches %v, want every track matched to a distinct detection", matches)
+				}
+			} else if !maps.Equal(matches, tt.want) {
+				t.Errorf("matches %v, want %v", matches, tt.want)
+			}
+			if !slices.Equal(unmatchedTracks, tt.wantTracks) {
+				t.Errorf("unmatched tracks %v, want %v", unmatchedTracks, tt.wantTracks)
+			}
+			if !slices.Equal(unmatchedDets, tt.wantDets) {
+				t.Errorf("unmatched detections %v, want %v", unmatchedDets, tt.wantDets)
+			}
+		})
+	}
+}
+
+func TestKalmanBox(t *testing.T) {
+	// a 20x10 box moving 10 pixels right and 5 pixels down per frame
+	at := func(frameId int64) image.Rectangle {
+		return image.Rect(0, 0, 20, 10).Add(image.Pt(10*int(frameId), 5*int(frameId)))
+	}
+	kb := newKalmanBox(at(0), 0)
+	if rec := kb.Rect(); rec != at(0) {
+		t.Fatalf("initial box %v, want %v", rec, at(0))
+	}
+
+	// without a velocity estimate the prediction stays in place
+	if rec := kb.Predict(1); rec != at(0) {
+		t.Errorf("first prediction %v, want %v", rec, at(0))
+	}
+	kb.Update(at(1))
+	for f := int64(2); f <= 20; f++ {
+		kb.Predict(f)
+		kb.Update(at(f))
+	}
+
+	near := func(got, want image.Rectangle) bool {
+		d := got.Min.Sub(want.Min)
+		return abs(d.X) <= 1 && abs(d.Y) <= 1 && abs(got.Dx()-want.Dx()) <= 1 && abs(got.Dy()-want.Dy()) <= 1
+	}
+	if rec := kb.Rect(); !near(rec, at(20)) {
+		t.Errorf("updated box %v, want %v", rec, at(20))
+	}
+	// the estimated velocity carries the box over frames without detections
+	if rec := kb.Predict(23); !near(rec, at(23)) {
+		t.Errorf("predicted box %v, want %v", rec, at(23))
+	}
+	// predicting the current or an older frame does not move the box
+	rec := kb.Rect()
+	if got := kb.Predict(23); got != rec {
+		t.Errorf("same frame prediction %v, want %v", got, rec)
+	}
+	if got := kb.Predict(21); got != rec {
+		t.Errorf("older frame prediction %v, want %v", got, rec)
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"image"
+	"math"
+)
+
+// kalman1D is a constant velocity Kalman filter for a single coordinate
+type kalman1D struct {
+	x, v float64
+	// state covariance
+	p [2][2]float64
+	// process and measurement noise
+	q, r float64
+}
+
+func newKalman1D(x, posVar, velVar, q, r float64) kalman1D {
+	return kalman1D{
+		x: x,
+		p: [2][2]float64{{posVar, 0}, {0, velVar}},
+		q: q,
+		r: r,
+	}
+}
+
+// predict advances the state by dt steps
+func (k *kalman1D) predict(dt float64) {
+	k.x += k.v * dt
+	p := k.p
+	k.p[0][0] = p[0][0] + dt*(p[0][1]+p[1][0]) + dt*dt*p[1][1] + k.q
+	k.p[0][1] = p[0][1] + dt*p[1][1]
+	k.p[1][0] = p[1][0] + dt*p[1][1]
+	k.p[1][1] = p[1][1] + k.q
+}
+
+// update corrects the state with the measured position z
+func (k *kalman1D) update(z float64) {
+	y := z - k.x
+	s := k.p[0][0] + k.r
+	k0, k1 := k.p[0][0]/s, k.p[1][0]/s
+	k.x += k0 * y
+	k.v += k1 * y
+	p := k.p
+	k.p[0][0] = (1 - k0) * p[0][0]
+	k.p[0][1] = (1 - k0) * p[0][1]
+	k.p[1][0] = p[1][0] - k1*p[0][0]
+	k.p[1][1] = p[1][1] - k1*p[0][1]
+}
+
+// kalmanBox estimates the motion of a box as in SORT: the centre and the area
+// follow a constant velocity model and the aspect ratio is kept constant.
+// The filter for each quantity is independent since the motion model does not
+// couple them.
+type kalmanBox struct {
+	cx, cy, s kalman1D
+	ratio     float64
+	// frame id the estimate refers to
+	frameId int64
+}
+
+func newKalmanBox(rec image.Rectangle, frameId int64) *kalmanBox {
+	cx, cy, s, r := boxToState(rec)
+	return &kalmanBox{
+		cx:      newKalman1D(cx, 10, 1000, 1, 1),
+		cy:      newKalman1D(cy, 10, 1000, 1, 1),
+		s:       newKalman1D(s, 10, 1000, 10, 10),
+		ratio:   r,
+		frameId: frameId,
+	}
+}
+
+// Predict advances the estimate to frameId and returns the predicted box
+func (kb *kalmanBox) Predict(frameId int64) image.Rectangle {
+	if dt := float64(frameId - kb.frameId); dt > 0 {
+		// the area must not become negative
+		if kb.s.x+kb.s.v*dt <= 0 {
+			kb.s.v = 0
+		}
+		kb.cx.predict(dt)
+		kb.cy.predict(dt)
+		kb.s.predict(dt)
+		kb.frameId = frameId
+	}
+	return kb.Rect()
+}
+
+// Update corrects the estimate with a detected box
+func (kb *kalmanBox) Update(rec image.Rectangle) {
+	cx, cy, s, r := boxToState(rec)
+	kb.cx.update(cx)
+	kb.cy.update(cy)
+	kb.s.update(s)
+	kb.ratio = r
+}
+
+// Rect returns the current estimate as a rectangle
+func (kb *kalmanBox) Rect() image.Rectangle {
+	s := math.Max(kb.s.x, 1)
+	w := math.Sqrt(s * kb.ratio)
+	h := s / w
+	return image.Rect(
+		int(math.Round(kb.cx.x-w/2)), int(math.Round(kb.cy.x-h/2)),
+		int(math.Round(kb.cx.x+w/2)), int(math.Round(kb.cy.x+h/2)))
+}
+
+// boxToState converts a rectangle into centre, area and aspect ratio
+func boxToState(rec image.Rectangle) (cx, cy, s, r float64) {
+	w, h := float64(rec.Dx()), float64(rec.Dy())
+	cx = float64(rec.Min.X) + w/2
+	cy = float64(rec.Min.Y) + h/2
+	s = w * h
+	r = 1
+	if h > 0 {
+		r = w / h
+	}
+	return cx, cy, s, r
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"image"
+	"log"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"github.com/etesami/detection-tracking-system/pkg/utils"
+	"gocv.io/x/gocv"
+)
+
+// Default minimum IoU between a track and a detection to be associated
+var kcfIoUThreshold = 0.5
+
+// kcfTracker runs one OpenCV KCF tracker per object and re-initialises it
+// whenever a detection is matched with the object
+type kcfTracker struct {
+	config *DtConfig
+}
+
+func (k *kcfTracker) Name() string {
+	return TrackerKCF
+}
+
+func (k *kcfTracker) Update(tc *TrackerClient, frame gocv.Mat, frameId int64, detections []*pb.BoundingBox) {
+	sourceName := "Detect"
+
+	tracks := make([]image.Rectangle, len(tc.trackerInstance))
+	for i, ti := range tc.trackerInstance {
+		tracks[i] = ti.store
+	}
+	// Each detection is assigned to at most one track and vice versa
+	matches, unmatchedTracks, notMatched := matchIoU(tracks, boxesToRects(detections), k.config.iouThreshold(kcfIoUThreshold), func(i, j int) bool {
+		return sameClass(tc.trackerInstance[i], detections[j])
+	})
+	for i2, i := range matches {
+		log.Printf("Frame [%d], [%s]: Detection [%d] matched track [%d] IoU: %f",
+			frameId, sourceName, i, tc.trackerInstance[i2].id, getIoU(tracks[i2], utils.BoxToRect(detections[i])))
+	}
+
+	log.Printf("Frame [%d], [%s]: Mathces: [%d], Unmatched: [%d]", frameId, sourceName, len(matches), len(notMatched))
+	// If the object is already being tracked, restart its tracker on the
+	// detected box while keeping the track identity
+	for i2, i := range matches {
+		tc.trackerInstance[i2].Reinit(frame, detections[i], frameId)
+	}
+	for _, i2 := range unmatchedTracks {
+		tc.trackerInstance[i2].MarkMissed()
+	}
+
+	// Tracks not confirmed by the detector for too long are dropped
+	removed := tc.RemoveLost(k.config.MaxMisses)
+
+	// If the object is not being tracked, add a new tracker instance
+	for _, i := range notMatched {
+		newTrackerInstance := tc.NewTrack(detections[i], frameId)
+		newTrackerInstance.InitTracker(frame)
+
+		tc.AddInstance(newTrackerInstance)
+	}
+	log.Printf("Frame [%d], [%s]: Removed tracks: [%d], Active tracks: [%d]", frameId, sourceName, removed, len(tc.trackerInstance))
+}
+
+func (k *kcfTracker) Predict(tc *TrackerClient, frame gocv.Mat, frameId int64) int {
+	lostInstances := 0
+	for _, trInstance := range tc.trackerInstance {
+		if ok := trInstance.UpdateTracker(frame, frameId); !ok {
+			lostInstances++
+		}
+	}
+	// Delete instances that have been lost for too long
+	tc.RemoveLost(k.config.MaxMisses)
+	return lostInstances
+}
@@ -2,8 +2,12 @@ package internal
 
 import (
 	"image"
+	"math"
 	"sync"
+	"time"
 
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"github.com/etesami/detection-tracking-system/pkg/utils"
 	"gocv.io/x/gocv"
 	"gocv.io/x/gocv/contrib"
 )
@@ -12,12 +16,39 @@ type TrackerClient struct {
 	mu              sync.Mutex
 	sourceId        string
 	trackerInstance []*TrackerInstance
+	// last track id assigned for this source
+	lastTrackId int64
+	// last frame that updated the tracks and when it happened
+	lastFrameId int64
+	updated     time.Time
 }
 
 type TrackerInstance struct {
 	// mu      sync.Mutex
 	tracker *gocv.Tracker
 	store   image.Rectangle
+	// motion estimate, used by the SORT and ByteTrack algorithms
+	kf *kalmanBox
+
+	// identity of the tracked object, preserved across re-initialisation
+	id      int64
+	classId int32
+	label   string
+	// frame ids of the first and the last frame the object was seen in
+	firstSeen int64
+	lastSeen  int64
+	// number of detections matched to this track
+	hits int
+	// number of consecutive frames the object was not found
+	misses int
+	// number of frames processed since the track was created
+	age int
+	// confidence of the latest matched detection
+	confidence float32
+	// displacement of the box centre in pixels per frame in the latest
+	// update, and the frame the box refers to
+	motion   float64
+	boxFrame int64
 }
 
 func (tc *TrackerClient) DeleteInstanceAt(index int) {
@@ -33,6 +64,14 @@ func (tc *TrackerClient) DeleteInstanceAt(index int) {
 	tc.trackerInstance = append(tc.trackerInstance[:index], tc.trackerInstance[index+1:]...)
 }
 
+// touch records the frame that updated the tracks
+func (tc *TrackerClient) touch(frameId int64) {
+	if frameId > tc.lastFrameId {
+		tc.lastFrameId = frameId
+	}
+	tc.updated = time.Now()
+}
+
 func (tc *TrackerClient) AddInstance(instance *TrackerInstance) {
 	if instance == nil {
 		return
@@ -40,6 +79,57 @@ func (tc *TrackerClient) AddInstance(instance *TrackerInstance) {
 	tc.trackerInstance = append(tc.trackerInstance, instance)
 }
 
+// NewTrack creates a tracker instance for a detection and assigns it
+// the next track id of the source
+func (tc *TrackerClient) NewTrack(box *pb.BoundingBox, frameId int64) *TrackerInstance {
+	return tc.newTrack(NewTrackerInstance(utils.BoxToRect(box)), box, frameId)
+}
+
+// NewKalmanTrack creates a track whose motion is estimated by a Kalman
+// filter instead of an image tracker
+func (tc *TrackerClient) NewKalmanTrack(box *pb.BoundingBox, frameId int64) *TrackerInstance {
+	rec := utils.BoxToRect(box)
+	ti := &TrackerInstance{
+		store: rec,
+		kf:    newKalmanBox(rec, frameId),
+	}
+	return tc.newTrack(ti, box, frameId)
+}
+
+func (tc *TrackerClient) newTrack(ti *TrackerInstance, box *pb.BoundingBox, frameId int64) *TrackerInstance {
+	tc.lastTrackId++
+	ti.id = tc.lastTrackId
+	ti.classId = box.ClassId
+	ti.label = box.Label
+	ti.confidence = box.Confidence
+	ti.firstSeen = frameId
+	ti.lastSeen = frameId
+	ti.boxFrame = frameId
+	ti.hits = 1
+	return ti
+}
+
+// RemoveLost deletes the instances that have missed more than maxMisses
+// consecutive frames and returns the number of deleted instances
+func (tc *TrackerClient) RemoveLost(maxMisses int) int {
+	kept := tc.trackerInstance[:0]
+	removed := 0
+	for _, ti := range tc.trackerInstance {
+		if ti.misses > maxMisses {
+			ti.deleteInstance()
+			removed++
+			continue
+		}
+		kept = append(kept, ti)
+	}
+	// clear the tail so the removed instances can be garbage collected
+	for i := len(kept); i < len(tc.trackerInstance); i++ {
+		tc.trackerInstance[i] = nil
+	}
+	tc.trackerInstance = kept
+	return removed
+}
+
 func NewTrackerInstance(rec image.Rectangle) *TrackerInstance {
 	tracker := contrib.NewTrackerKCF()
 	return &TrackerInstance{
@@ -54,13 +144,69 @@ func (ti *TrackerInstance) InitTracker(frame gocv.Mat) {
 	}
 }
 
-func (ti *TrackerInstance) UpdateTracker(frame gocv.Mat) bool {
+// Reinit restarts the underlying tracker on a matched detection while
+// keeping the identity and the counters of the track
+func (ti *TrackerInstance) Reinit(frame gocv.Mat, box *pb.BoundingBox, frameId int64) {
+	ti.deleteInstance()
+	tracker := contrib.NewTrackerKCF()
+	ti.tracker = &tracker
+	ti.setBox(utils.BoxToRect(box), frameId)
+	ti.InitTracker(frame)
+	ti.hit(box, frameId)
+}
+
+// Correct updates the motion estimate of the track with a matched detection
+func (ti *TrackerInstance) Correct(box *pb.BoundingBox, frameId int64) {
+	rec := utils.BoxToRect(box)
+	if ti.kf != nil {
+		ti.kf.Update(rec)
+		rec = ti.kf.Rect()
+	}
+	ti.setBox(rec, frameId)
+	ti.hit(box, frameId)
+}
+
+// hit records a detection matched with the track
+func (ti *TrackerInstance) hit(box *pb.BoundingBox, frameId int64) {
+	if box.Label != "" {
+		ti.classId = box.ClassId
+		ti.label = box.Label
+	}
+	ti.confidence = box.Confidence
+	ti.lastSeen = frameId
+	ti.hits++
+	ti.misses = 0
+	ti.age++
+}
+
+// setBox moves the track to a new box and updates its motion
+func (ti *TrackerInstance) setBox(rec image.Rectangle, frameId int64) {
+	frames := frameId - ti.boxFrame
+	if frames > 0 {
+		prev, next := center(ti.store), center(rec)
+		ti.motion = math.Hypot(next.X-prev.X, next.Y-prev.Y) / float64(frames)
+		ti.boxFrame = frameId
+	}
+	ti.store = rec
+}
+
+// MarkMissed records a frame in which the object was not found
+func (ti *TrackerInstance) MarkMissed() {
+	ti.misses++
+	ti.age++
+}
+
+func (ti *TrackerInstance) UpdateTracker(frame gocv.Mat, frameId int64) bool {
 	if ti.tracker != nil {
 		rec, ok := (*ti.tracker).Update(frame)
 		if ok {
-			ti.store = rec
+			ti.setBox(rec, frameId)
+			ti.lastSeen = frameId
+			ti.misses = 0
+			ti.age++
 			return true
 		}
+		ti.MarkMissed()
 		return false
 	}
 	return false
@@ -72,3 +218,9 @@ func (ti *TrackerInstance) deleteInstance() {
 		ti.tracker = nil      // clear the pointer
 	}
 }
+
+// sameClass reports whether a detection may be associated with a track,
+// detections or tracks without a label match any class
+func sameClass(ti *TrackerInstance, det *pb.BoundingBox) bool {
+	return ti.label == "" || det.Label == "" || ti.classId == det.ClassId
+}
@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	metric "github.com/etesami/detection-tracking-system/pkg/metric"
+)
+
+// Policies for a frame arriving after a later frame of its source has been
+// applied already
+const (
+	// LateDrop discards the late frame
+	LateDrop = "drop"
+	// LateApply applies the late frame out of order
+	LateApply = "apply"
+	// LateRerun applies a late detection and runs the tracking frames
+	// received after it again, late tracking frames are discarded since the
+	// tracks have already moved past them. The frames run again are applied
+	// as replays, their results were published already.
+	LateRerun = "rerun"
+)
+
+// Defaults of the reorder window
+const (
+	defaultReorderWindow  = 8
+	defaultReorderMaxWait = time.Second
+	// frames waiting to be applied, in reorder windows, the frames beyond it
+	// are dropped until the tracking catches up
+	readyWindows = 4
+)
+
+// ParseLatePolicy checks the late frame policy, drop is used if it is empty
+func ParseLatePolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return LateDrop, nil
+	case LateDrop, LateApply, LateRerun:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown late frame policy: %s", policy)
+	}
+}
+
+// update is the detection or tracking update of a frame, apply is called
+// with replay set when the frame is run again after a late detection
+type update struct {
+	frameId   int64
+	prevId    int64
+	detection bool
+	apply     func(replay bool)
+	replay    bool
+	received  time.Time
+}
+
+// Sequencer applies the updates of a source one at a time and strictly in the
+// order the aggregator sent the frames. A frame is applied once the frame sent
+// before it has been applied; frames arriving ahead of it are held in a
+// bounded reorder window. When the window is full or the oldest held frame has
+// waited too long, the missing frames are given up on.
+type Sequencer struct {
+	sourceId string
+	window   int
+	maxReady int
+	maxWait  time.Duration
+	policy   string
+	metric   *metric.Metric
+
+	mu sync.Mutex
+	// id of the last frame applied in order
+	last int64
+	// frames waiting for the frames sent before them
+	pending map[int64]*update
+	// frames to be applied, in order
+	ready    []*update
+	draining bool
+	timer    *time.Timer
+	// when the last frame was submitted
+	submitted time.Time
+	// id of the last applied detection and the tracking updates applied
+	// after it, kept to run them again after a late detection
+	lastDetection int64
+	history       []*update
+}
+
+// NewSequencer creates the sequencer of a source
+func NewSequencer(sourceId string, c *DtConfig, m *metric.Metric) *Sequencer {
+	window := c.ReorderWindow
+	if window <= 0 {
+		window = defaultReorderWindow
+	}
+	maxWait := c.ReorderMaxWait
+	if maxWait <= 0 {
+		maxWait = defaultReorderMaxWait
+	}
+	policy := c.LateFramePolicy
+	if policy == "" {
+		policy = LateDrop
+	}
+	return &Sequencer{
+		sourceId: sourceId,
+		window:   window,
+		maxReady: window * readyWindows,
+		maxWait:  maxWait,
+		policy:   policy,
+		metric:   m,
+		pending:  make(map[int64]*update),
+	}
+}
+
+// Submit queues the update of a frame, prevId is the frame the aggregator
+// sent before it. The update is applied asynchronously once its turn comes.
+func (sq *Sequencer) Submit(frameId, prevId int64, detection bool, apply func(replay bool)) {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+
+	u := &update{
+		frameId:   frameId,
+		prevId:    prevId,
+		detection: detection,
+		apply:     apply,
+		received:  time.Now(),
+	}
+	sq.submitted = u.received
+
+	// The first frame of a stream follows no frame, if later frames were
+	// applied already the aggregator has started the source over
+	if prevId == 0 && sq.last > 0 && frameId <= sq.last {
+		log.Printf("Frame [%d], [%s]: Stream restarted after frame [%d]", frameId, sq.sourceId, sq.last)
+		sq.restart()
+	}
+
+	switch _, held := sq.pending[frameId]; {
+	case frameId <= sq.last:
+		sq.late(u)
+	case held:
+		log.Printf("Frame [%d], [%s]: Duplicate frame dropped", frameId, sq.sourceId)
+		sq.metric.AddDroppedFrame(sq.sourceId, "duplicate")
+	default:
+		if prevId > sq.last {
+			sq.metric.AddReorderedFrame(sq.sourceId)
+		}
+		sq.pending[frameId] = u
+	}
+
+	sq.release()
+	sq.drain()
+}
+
+// restart hands the frames held from the previous stream over in order and
+// starts the sequence from the beginning, sq.mu must be held
+func (sq *Sequencer) restart() {
+	for len(sq.pending) > 0 {
+		sq.take(sq.oldest())
+	}
+	sq.last = 0
+	sq.lastDetection = 0
+	sq.history = nil
+}
+
+// late handles a frame whose turn has passed, sq.mu must be held
+func (sq *Sequencer) late(u *update) {
+	switch {
+	case sq.policy == LateApply:
+		if sq.push(u) {
+			log.Printf("Frame [%d], [%s]: Late frame applied out of order", u.frameId, sq.sourceId)
+		}
+	case sq.policy == LateRerun && u.detection && u.frameId > sq.lastDetection:
+		if !sq.push(u) {
+			return
+		}
+		// the frames up to the detection are not run again by a later one
+		sq.lastDetection = u.frameId
+		history := sq.history[:0]
+		for _, h := range sq.history {
+			if h.frameId > u.frameId {
+				sq.push(&update{frameId: h.frameId, apply: h.apply, replay: true})
+				history = append(history, h)
+			}
+		}
+		sq.history = history
+		log.Printf("Frame [%d], [%s]: Late detection applied, running [%d] frames again", u.frameId, sq.sourceId, len(history))
+	default:
+		log.Printf("Frame [%d], [%s]: Late frame dropped, last applied frame: [%d]", u.frameId, sq.sourceId, sq.last)
+		sq.metric.AddDroppedFrame(sq.sourceId, "late")
+	}
+}
+
+// release moves the frames whose turn has come to the ready list, skipping
+// missing frames when the window is full or has waited too long. sq.mu must
+// be held.
+func (sq *Sequencer) release() {
+	for len(sq.pending) > 0 {
+		var next *update
+		for _, u := range sq.pending {
+			if u.prevId <= sq.last && (next == nil || u.frameId < next.frameId) {
+				next = u
+			}
+		}
+		if next == nil {
+			oldest := sq.oldest()
+			waited := time.Since(oldest.received)
+			if len(sq.pending) < sq.window && waited < sq.maxWait {
+				sq.schedule(sq.maxWait - waited)
+				return
+			}
+			log.Printf("Frame [%d], [%s]: Gave up waiting for frame [%d]", oldest.frameId, sq.sourceId, oldest.prevId)
+			next = oldest
+		}
+		sq.take(next)
+	}
+}
+
+// oldest returns the held frame with the lowest id, sq.mu must be held
+func (sq *Sequencer) oldest() *update {
+	var oldest *update
+	for _, u := range sq.pending {
+		if oldest == nil || u.frameId < oldest.frameId {
+			oldest = u
+		}
+	}
+	return oldest
+}
+
+// take moves a held frame to the ready list, sq.mu must be held
+func (sq *Sequencer) take(u *update) {
+	delete(sq.pending, u.frameId)
+	sq.last = u.frameId
+	if !sq.push(u) || sq.policy != LateRerun {
+		return
+	}
+	if u.detection {
+		sq.lastDetection = u.frameId
+		sq.history = nil
+		return
+	}
+	sq.history = append(sq.history, u)
+	if len(sq.history) > sq.window {
+		sq.history = sq.history[1:]
+	}
+}
+
+// push appends a frame to the ready list unless maxReady frames are waiting
+// to be applied already, in which case the frame is dropped. sq.mu must be
+// held.
+func (sq *Sequencer) push(u *update) bool {
+	if len(sq.ready) >= sq.maxReady {
+		log.Printf("Frame [%d], [%s]: Dropped, [%d] frames waiting to be applied", u.frameId, sq.sourceId, len(sq.ready))
+		sq.metric.AddDroppedFrame(sq.sourceId, "overflow")
+		return false
+	}
+	sq.ready = append(sq.ready, u)
+	return true
+}
+
+// schedule releases the held frames again after d unless new frames arrive
+// first, sq.mu must be held
+func (sq *Sequencer) schedule(d time.Duration) {
+	if sq.timer != nil {
+		sq.timer.Stop()
+	}
+	sq.timer = time.AfterFunc(d, func() {
+		sq.mu.Lock()
+		defer sq.mu.Unlock()
+		sq.release()
+		sq.drain()
+	})
+}
+
+// drain starts applying the ready frames unless that is in progress already,
+// sq.mu must be held
+func (sq *Sequencer) drain() {
+	if sq.draining || len(sq.ready) == 0 {
+		return
+	}
+	sq.draining = true
+	go func() {
+		for {
+			sq.mu.Lock()
+			if len(sq.ready) == 0 {
+				sq.draining = false
+				sq.mu.Unlock()
+				return
+			}
+			u := sq.ready[0]
+			sq.ready = sq.ready[1:]
+			sq.mu.Unlock()
+			u.apply(u.replay)
+		}
+	}()
+}
+
+// expire reports whether the sequencer has held no frames and received none
+// for idle, the timer and the frames kept for reruns are then released
+func (sq *Sequencer) expire(idle time.Duration) bool {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	if len(sq.pending) > 0 || len(sq.ready) > 0 || sq.draining || time.Since(sq.submitted) < idle {
+		return false
+	}
+	if sq.timer != nil {
+		sq.timer.Stop()
+		sq.timer = nil
+	}
+	sq.history = nil
+	return true
+}
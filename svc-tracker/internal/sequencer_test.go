@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	metric "github.com/etesami/detection-tracking-system/pkg/metric"
+)
+
+// submission is a frame submitted to the sequencer after waiting for sleep
+type submission struct {
+	frameId   int64
+	prevId    int64
+	detection bool
+	sleep     time.Duration
+}
+
+// recorder collects the applied frames, replays are marked with an r
+type recorder struct {
+	mu      sync.Mutex
+	applied []string
+}
+
+func (r *recorder) apply(frameId int64) func(bool) {
+	return func(replay bool) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if replay {
+			r.applied = append(r.applied, fmt.Sprintf("%dr", frameId))
+			return
+		}
+		r.applied = append(r.applied, fmt.Sprint(frameId))
+	}
+}
+
+func (r *recorder) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.applied)
+}
+
+func TestSequencer(t *testing.T) {
+	const maxWait = 20 * time.Millisecond
+	tests := []struct {
+		name   string
+		window int
+		policy string
+		frames []submission
+		want   []string
+	}{
+		{
+			name:   "in order",
+			frames: []submission{{1, 0, true, 0}, {2, 1, false, 0}, {3, 2, false, 0}},
+			want:   []string{"1", "2", "3"},
+		},
+		{
+			name:   "reordered",
+			frames: []submission{{1, 0, true, 0}, {3, 2, false, 0}, {4, 3, false, 0}, {2, 1, false, 0}},
+			want:   []string{"1", "2", "3", "4"},
+		},
+		{
+			name:   "duplicate dropped",
+			frames: []submission{{1, 0, true, 0}, {3, 2, false, 0}, {3, 2, false, 0}, {2, 1, false, 0}},
+			want:   []string{"1", "2", "3"},
+		},
+		{
+			name:   "gives up when the window is full",
+			window: 2,
+			frames: []submission{{1, 0, true, 0}, {3, 2, false, 0}, {4, 3, false, 0}},
+			want:   []string{"1", "3", "4"},
+		},
+		{
+			name:   "gives up after the max wait",
+			frames: []submission{{1, 0, true, 0}, {3, 2, false, 0}},
+			want:   []string{"1", "3"},
+		},
+		{
+			name:   "stream restarted",
+			frames: []submission{{1, 0, true, 0}, {2, 1, false, 0}, {3, 2, false, 0}, {1, 0, true, 0}, {2, 1, false, 0}},
+			want:   []string{"1", "2", "3", "1", "2"},
+		},
+		{
+			name:   "late frame dropped",
+			policy: LateDrop,
+			frames: []submission{{1, 0, true, 0}, {3, 2, false, 0}, {2, 1, true, 3 * maxWait}},
+			want:   []string{"1", "3"},
+		},
+		{
+			name:   "late frame applied",
+			policy: LateApply,
+			frames: []submission{{1, 0, true, 0}, {3, 2, false, 0}, {2, 1, false, 3 * maxWait}},
+			want:   []string{"1", "3", "2"},
+		},
+		{
+			name:   "late detection reruns the later frames",
+			window: 2,
+			policy: LateRerun,
+			frames: []submission{{1, 0, true, 0}, {3, 2, false, 0}, {4, 3, false, 0}, {2, 1, true, 0}},
+			want:   []string{"1", "3", "4", "2", "3r", "4r"},
+		},
+		{
+			name:   "late tracking frame dropped on rerun",
+			window: 2,
+			policy: LateRerun,
+			frames: []submission{{1, 0, true, 0}, {3, 2, false, 0}, {4, 3, false, 0}, {2, 1, false, 0}},
+			want:   []string{"1", "3", "4"},
+		},
+		{
+			name:   "late detection older than the last detection dropped on rerun",
+			window: 2,
+			policy: LateRerun,
+			frames: []submission{{1, 0, true, 0}, {3, 2, true, 0}, {4, 3, false, 0}, {2, 1, true, 0}},
+			want:   []string{"1", "3", "4"},
+		},
+		{
+			name:   "late detection older than a rerun detection dropped",
+			window: 2,
+			policy: LateRerun,
+			frames: []submission{{1, 0, true, 0}, {4, 3, false, 0}, {5, 4, false, 0}, {3, 2, true, 0}, {2, 1, true, 0}},
+			want:   []string{"1", "4", "5", "3", "4r", "5r"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sq := NewSequencer("cam", &DtConfig{
+				ReorderWindow:   tt.window,
+				ReorderMaxWait:  maxWait,
+				LateFramePolicy: tt.policy,
+			}, &metric.Metric{})
+			rec := &recorder{}
+			for _, f := range tt.frames {
+				time.Sleep(f.sleep)
+				sq.Submit(f.frameId, f.prevId, f.detection, rec.apply(f.frameId))
+			}
+
+			deadline := time.Now().Add(time.Second)
+			for len(rec.snapshot()) < len(tt.want) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			// nothing else is applied once the held frames are given up on
+			time.Sleep(3 * maxWait)
+			if got := rec.snapshot(); !slices.Equal(got, tt.want) {
+				t.Errorf("applied %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSequencerExpire(t *testing.T) {
+	sq := NewSequencer("cam", &DtConfig{ReorderMaxWait: time.Hour}, &metric.Metric{})
+	rec := &recorder{}
+	sq.Submit(1, 0, true, rec.apply(1))
+	sq.Submit(3, 2, false, rec.apply(3))
+
+	if sq.expire(0) {
+		t.Fatal("expired while holding a frame")
+	}
+	sq.Submit(2, 1, false, rec.apply(2))
+	deadline := time.Now().Add(time.Second)
+	for len(rec.snapshot()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if sq.expire(time.Hour) {
+		t.Fatal("expired before the idle timeout")
+	}
+	// the drain goroutine may still be finishing
+	for !sq.expire(0) {
+		if time.Now().After(deadline) {
+			t.Fatal("not expired once idle")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSequencerOverflow(t *testing.T) {
+	const window = 2
+	sq := NewSequencer("cam", &DtConfig{ReorderWindow: window}, &metric.Metric{})
+	rec := &recorder{}
+
+	// the first frame blocks the frames after it from being applied
+	block := make(chan struct{})
+	sq.Submit(1, 0, true, func(replay bool) {
+		<-block
+		rec.apply(1)(replay)
+	})
+	deadline := time.Now().Add(time.Second)
+	for waiting := 1; waiting > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		sq.mu.Lock()
+		waiting = len(sq.ready)
+		sq.mu.Unlock()
+	}
+	for f := int64(2); f <= 20; f++ {
+		sq.Submit(f, f-1, false, rec.apply(f))
+	}
+	close(block)
+
+	var want []string
+	for f := 1; f <= 1+window*readyWindows; f++ {
+		want = append(want, fmt.Sprint(f))
+	}
+	for len(rec.snapshot()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := rec.snapshot(); !slices.Equal(got, want) {
+		t.Errorf("applied %v, want %v", got, want)
+	}
+}
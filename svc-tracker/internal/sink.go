@@ -0,0 +1,273 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Sink receives the tracking result of every processed frame
+type Sink interface {
+	Name() string
+	Publish(r *pb.TrackingResult) error
+	Close() error
+}
+
+// ResultPublisher hands the tracking results to the sinks in the background
+// so a slow sink does not hold back tracking. Results are dropped when the
+// queue is full.
+type ResultPublisher struct {
+	sinks []Sink
+	queue chan *pb.TrackingResult
+	done  chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewResultPublisher starts delivering results to the given sinks
+func NewResultPublisher(queueSize int, sinks ...Sink) *ResultPublisher {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	p := &ResultPublisher{
+		sinks: sinks,
+		queue: make(chan *pb.TrackingResult, queueSize),
+		done:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues a result for delivery
+func (p *ResultPublisher) Publish(r *pb.TrackingResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- r:
+	default:
+		log.Printf("Frame [%d], [%s]: Result queue is full, dropping result", r.FrameId, r.SourceId)
+	}
+}
+
+func (p *ResultPublisher) run() {
+	defer close(p.done)
+	for r := range p.queue {
+		for _, sink := range p.sinks {
+			if err := sink.Publish(r); err != nil {
+				log.Printf("Frame [%d], [%s]: Error publishing result to [%s]: %v", r.FrameId, r.SourceId, sink.Name(), err)
+			}
+		}
+	}
+}
+
+// Close delivers the queued results and closes the sinks
+func (p *ResultPublisher) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+	<-p.done
+	for _, sink := range p.sinks {
+		if err := sink.Close(); err != nil {
+			log.Printf("Error closing sink [%s]: %v", sink.Name(), err)
+		}
+	}
+}
+
+// JSONLSink appends one JSON document per result to a file
+type JSONLSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewJSONLSink(path string) (*JSONLSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening results file: %v", err)
+	}
+	return &JSONLSink{file: f}, nil
+}
+
+func (j *JSONLSink) Name() string {
+	return "jsonl"
+}
+
+func (j *JSONLSink) Publish(r *pb.TrackingResult) error {
+	data, err := protojson.Marshal(r)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+func (j *JSONLSink) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// WebhookSink posts every result as JSON to an HTTP endpoint
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (w *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookSink) Publish(r *pb.TrackingResult) error {
+	data, err := protojson.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *WebhookSink) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// StreamSink forwards the results to the SubscribeTracks subscribers
+type StreamSink struct {
+	mu          sync.Mutex
+	nextId      int
+	subscribers map[int]*subscriber
+}
+
+type subscriber struct {
+	sourceId string
+	labels   []string
+	results  chan *pb.TrackingResult
+}
+
+func NewStreamSink() *StreamSink {
+	return &StreamSink{
+		subscribers: make(map[int]*subscriber),
+	}
+}
+
+func (ss *StreamSink) Name() string {
+	return "grpc"
+}
+
+// Subscribe registers a subscriber for a source, or for all sources when
+// sourceId is empty, receiving only the tracks with the given labels if any.
+// The returned function removes the subscriber.
+func (ss *StreamSink) Subscribe(sourceId string, labels []string, bufferSize int) (<-chan *pb.TrackingResult, func()) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.nextId++
+	id := ss.nextId
+	sub := &subscriber{
+		sourceId: sourceId,
+		labels:   labels,
+		results:  make(chan *pb.TrackingResult, bufferSize),
+	}
+	ss.subscribers[id] = sub
+	return sub.results, func() {
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+		if _, ok := ss.subscribers[id]; ok {
+			delete(ss.subscribers, id)
+			close(sub.results)
+		}
+	}
+}
+
+// Publish sends the result to the matching subscribers, a subscriber that
+// does not keep up misses results
+func (ss *StreamSink) Publish(r *pb.TrackingResult) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for _, sub := range ss.subscribers {
+		if sub.sourceId != "" && sub.sourceId != r.SourceId {
+			continue
+		}
+		res := r
+		if len(sub.labels) > 0 {
+			// the result is shared by all sinks, filter a copy of it
+			res = &pb.TrackingResult{
+				SourceId:  r.SourceId,
+				FrameId:   r.FrameId,
+				Timestamp: r.Timestamp,
+				Tracks:    filterTracks(r.Tracks, sub.labels),
+			}
+		}
+		select {
+		case sub.results <- res:
+		default:
+		}
+	}
+	return nil
+}
+
+func (ss *StreamSink) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for id, sub := range ss.subscribers {
+		delete(ss.subscribers, id)
+		close(sub.results)
+	}
+	return nil
+}
+
+// SubscribeTracks streams the tracking results to the caller until it
+// cancels the call or the server shuts down
+func (s *Server) SubscribeTracks(req *pb.TrackingStateRequest, stream pb.DetectionTrackingPipeline_SubscribeTracksServer) error {
+	if s.Subscribers == nil {
+		return fmt.Errorf("result streaming is not enabled")
+	}
+	results, unsubscribe := s.Subscribers.Subscribe(req.GetSourceId(), req.GetLabels(), 64)
+	defer unsubscribe()
+	log.Printf("New tracking result subscriber for source [%s]", req.GetSourceId())
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case r, ok := <-results:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(r); err != nil {
+				log.Printf("Error sending tracking result: %v", err)
+				return err
+			}
+		}
+	}
+}
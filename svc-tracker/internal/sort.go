@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"image"
+	"log"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"gocv.io/x/gocv"
+)
+
+// Default minimum IoU between a predicted track and a detection to be associated
+var sortIoUThreshold = 0.3
+
+// sortTracker implements SORT: the motion of each object is estimated with a
+// Kalman filter and detections are assigned to the predicted boxes with the
+// Hungarian algorithm on IoU. No image tracker is run per object.
+type sortTracker struct {
+	config *DtConfig
+}
+
+func (st *sortTracker) Name() string {
+	return TrackerSORT
+}
+
+func (st *sortTracker) Update(tc *TrackerClient, frame gocv.Mat, frameId int64, detections []*pb.BoundingBox) {
+	sourceName := "Detect"
+
+	predicted := predictTracks(tc.trackerInstance, frameId)
+	matches, unmatchedTracks, unmatchedDets := matchIoU(predicted, boxesToRects(detections), st.config.iouThreshold(sortIoUThreshold), func(i, j int) bool {
+		return sameClass(tc.trackerInstance[i], detections[j])
+	})
+	log.Printf("Frame [%d], [%s]: Mathces: [%d], Unmatched: [%d]", frameId, sourceName, len(matches), len(unmatchedDets))
+
+	for i, j := range matches {
+		tc.trackerInstance[i].Correct(detections[j], frameId)
+	}
+	for _, i := range unmatchedTracks {
+		tc.trackerInstance[i].MarkMissed()
+	}
+	removed := tc.RemoveLost(st.config.MaxMisses)
+
+	for _, j := range unmatchedDets {
+		tc.AddInstance(tc.NewKalmanTrack(detections[j], frameId))
+	}
+	log.Printf("Frame [%d], [%s]: Removed tracks: [%d], Active tracks: [%d]", frameId, sourceName, removed, len(tc.trackerInstance))
+}
+
+// Predict moves the tracks along their estimated motion. Tracks are only
+// counted as missed on frames that went through the detector.
+func (st *sortTracker) Predict(tc *TrackerClient, frame gocv.Mat, frameId int64) int {
+	ageTracks(tc.trackerInstance, frameId)
+	return 0
+}
+
+// predictTracks advances the Kalman filter of each track to frameId and
+// returns the predicted boxes
+func predictTracks(tracks []*TrackerInstance, frameId int64) []image.Rectangle {
+	predicted := make([]image.Rectangle, len(tracks))
+	for i, ti := range tracks {
+		if ti.kf != nil {
+			ti.setBox(ti.kf.Predict(frameId), frameId)
+		}
+		predicted[i] = ti.store
+	}
+	return predicted
+}
+
+// ageTracks advances the tracks to a frame without detections, the frames
+// with detections age the tracks when they are matched or missed
+func ageTracks(tracks []*TrackerInstance, frameId int64) {
+	predictTracks(tracks, frameId)
+	for _, ti := range tracks {
+		ti.age++
+	}
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"github.com/etesami/detection-tracking-system/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// GetTrackingState returns the active tracks of the requested source,
+// or of all sources when no source id is given
+func (s *Server) GetTrackingState(ctx context.Context, req *pb.TrackingStateRequest) (*pb.TrackingState, error) {
+	state, found := s.TrackingState(req.GetSourceId(), req.GetLabels())
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "source [%s] is not tracked", req.GetSourceId())
+	}
+	return state, nil
+}
+
+// TrackingState takes a snapshot of the tracks of a source, or of all sources
+// when sourceId is empty, keeping only the tracks with the given labels if
+// any. It reports false if the source is not tracked.
+func (s *Server) TrackingState(sourceId string, labels []string) (*pb.TrackingState, bool) {
+	s.mu.RLock()
+	clients := make([]*TrackerClient, 0, len(s.Trackers))
+	if sourceId != "" {
+		trClient, found := s.Trackers[sourceId]
+		if !found {
+			s.mu.RUnlock()
+			return nil, false
+		}
+		clients = append(clients, trClient)
+	} else {
+		for _, trClient := range s.Trackers {
+			clients = append(clients, trClient)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].sourceId < clients[j].sourceId
+	})
+
+	state := &pb.TrackingState{
+		Sources: make([]*pb.SourceTracks, 0, len(clients)),
+	}
+	for _, trClient := range clients {
+		st := trClient.snapshot()
+		st.Tracks = filterTracks(st.Tracks, labels)
+		state.Sources = append(state.Sources, st)
+	}
+	return state, true
+}
+
+// snapshot copies the tracks of the client
+func (tc *TrackerClient) snapshot() *pb.SourceTracks {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	st := &pb.SourceTracks{
+		SourceId:    tc.sourceId,
+		LastFrameId: tc.lastFrameId,
+		Tracks:      tc.tracks(),
+	}
+	if !tc.updated.IsZero() {
+		st.UpdatedTimestamp = tc.updated.Format(time.RFC3339Nano)
+	}
+	return st
+}
+
+// result builds the tracking result of a frame, stamped with the time the
+// frame was captured since it may be applied late. tc.mu must be held.
+func (tc *TrackerClient) result(metadata *pb.FrameMetadata) *pb.TrackingResult {
+	timestamp := metadata.Timestamp
+	if timestamp == "" {
+		timestamp = time.Now().Format(time.RFC3339Nano)
+	}
+	return &pb.TrackingResult{
+		SourceId:  tc.sourceId,
+		FrameId:   metadata.FrameId,
+		Timestamp: timestamp,
+		Tracks:    tc.tracks(),
+	}
+}
+
+// tracks copies the active tracks, tc.mu must be held
+func (tc *TrackerClient) tracks() []*pb.Track {
+	tracks := make([]*pb.Track, 0, len(tc.trackerInstance))
+	for _, ti := range tc.trackerInstance {
+		box := utils.RectToBox(ti.store)
+		box.ClassId = ti.classId
+		box.Label = ti.label
+		box.Confidence = ti.confidence
+		tracks = append(tracks, &pb.Track{
+			TrackId:          ti.id,
+			Box:              box,
+			FirstSeenFrameId: ti.firstSeen,
+			LastSeenFrameId:  ti.lastSeen,
+			Hits:             int32(ti.hits),
+			Misses:           int32(ti.misses),
+			Age:              int32(ti.age),
+		})
+	}
+	return tracks
+}
+
+// filterTracks keeps the tracks with one of the labels, all tracks are kept
+// if no label is given
+func filterTracks(tracks []*pb.Track, labels []string) []*pb.Track {
+	if len(labels) == 0 {
+		return tracks
+	}
+	kept := make([]*pb.Track, 0, len(tracks))
+	for _, t := range tracks {
+		if slices.Contains(labels, t.GetBox().GetLabel()) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// Health summarises the tracks of a source for the aggregator, it is nil
+// if the source is not tracked
+func (s *Server) Health(sourceId string) *pb.TrackerHealth {
+	s.mu.RLock()
+	trClient, found := s.Trackers[sourceId]
+	s.mu.RUnlock()
+	if !found {
+		return nil
+	}
+
+	trClient.mu.Lock()
+	defer trClient.mu.Unlock()
+
+	h := &pb.TrackerHealth{
+		ActiveTracks: int32(len(trClient.trackerInstance)),
+	}
+	var confidence, motion float64
+	confident := 0
+	for _, ti := range trClient.trackerInstance {
+		if ti.misses > 0 {
+			h.LostTracks++
+		}
+		if ti.confidence > 0 {
+			confidence += float64(ti.confidence)
+			confident++
+		}
+		motion += ti.motion
+	}
+	if confident > 0 {
+		h.MeanConfidence = float32(confidence / float64(confident))
+	}
+	if len(trClient.trackerInstance) > 0 {
+		h.MeanMotion = float32(motion / float64(len(trClient.trackerInstance)))
+	}
+	return h
+}
+
+// TracksHandler serves the tracking state as JSON, the source can be
+// selected with the source_id query parameter and the classes with one or
+// more label parameters
+func (s *Server) TracksHandler(w http.ResponseWriter, r *http.Request) {
+	sourceId := r.URL.Query().Get("source_id")
+	state, found := s.TrackingState(sourceId, r.URL.Query()["label"])
+	if !found {
+		http.Error(w, "source is not tracked", http.StatusNotFound)
+		return
+	}
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(state)
+	if err != nil {
+		log.Printf("Error marshalling tracking state: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing tracking state: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"fmt"
+
+	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
+	"gocv.io/x/gocv"
+)
+
+// Names of the available tracking algorithms
+const (
+	TrackerKCF       = "kcf"
+	TrackerSORT      = "sort"
+	TrackerByteTrack = "bytetrack"
+)
+
+// Tracker is a multi-object tracking algorithm. It maintains the tracks of a
+// single source, the caller holds the lock of the source while calling it.
+type Tracker interface {
+	// Name returns the name of the algorithm
+	Name() string
+	// Update associates the detections of a frame with the tracks of the source
+	Update(tc *TrackerClient, frame gocv.Mat, frameId int64, detections []*pb.BoundingBox)
+	// Predict advances the tracks to a frame without detections and
+	// returns the number of tracks that were not found on it
+	Predict(tc *TrackerClient, frame gocv.Mat, frameId int64) int
+}
+
+// NewTracker returns the tracking algorithm with the given name,
+// KCF is used when no name is given
+func NewTracker(name string, c *DtConfig) (Tracker, error) {
+	switch name {
+	case "", TrackerKCF:
+		return &kcfTracker{config: c}, nil
+	case TrackerSORT:
+		return &sortTracker{config: c}, nil
+	case TrackerByteTrack:
+		return &byteTracker{config: c}, nil
+	default:
+		return nil, fmt.Errorf("unknown tracker algorithm: %s", name)
+	}
+}
@@ -2,29 +2,33 @@ package internal
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"image"
-	"image/color"
+	"io"
 	"log"
+	"sync"
 	"time"
 
-	api "github.com/etesami/detection-tracking-system/api"
+	"github.com/etesami/detection-tracking-system/pkg/codec"
+	metric "github.com/etesami/detection-tracking-system/pkg/metric"
 	pb "github.com/etesami/detection-tracking-system/pkg/protoc"
 	"gocv.io/x/gocv"
 )
 
 type Server struct {
 	pb.UnimplementedDetectionTrackingPipelineServer
+	// mu guards the Trackers and sequencers maps, each client has its own lock
+	mu       sync.RWMutex
 	Trackers map[string]*TrackerClient
 	DtConfig *DtConfig
-}
-
-type detectionData struct {
-	SourceId  string
-	Timestamp string
-	FrameId   int64
-	Boxes     []image.Rectangle
+	Metric   *metric.Metric
+	// sequencers apply the updates of each source in frame order
+	sequencers map[string]*Sequencer
+	// Strategy is the tracking algorithm used for all sources
+	Strategy Tracker
+	// Results receives the tracking result of every processed frame
+	Results *ResultPublisher
+	// Subscribers serves the SubscribeTracks streams
+	Subscribers *StreamSink
 }
 
 // YoloV8 detector model
@@ -36,14 +40,33 @@ type DtConfig struct {
 	SaveImagePath        string
 	SaveImageFrequencyTr int
 	SaveImageFrequencyDt int
+	// Number of consecutive frames a track may be missed before it is dropped
+	MaxMisses int
+	// Minimum IoU for a detection to be associated with a track, the
+	// default of the tracking algorithm is used when it is not set
+	IoUThreshold float64
+	// Number of frames held while waiting for an earlier frame of their
+	// source, how long they wait, and what happens to frames arriving late
+	ReorderWindow   int
+	ReorderMaxWait  time.Duration
+	LateFramePolicy string
 }
 
-// AddClient adds a new client connection data to the server
-// and starts a new video input stream for that client
-func (s *Server) AddDetections(sourceId string, frameId int64, frame []byte, detections []image.Rectangle) {
+// iouThreshold returns the configured gating threshold or the given default
+func (c *DtConfig) iouThreshold(def float64) float64 {
+	if c.IoUThreshold > 0 {
+		return c.IoUThreshold
+	}
+	return def
+}
+
+// AddDetections passes the detections of a frame to the tracking algorithm
+// which matches them with the existing tracks of the source
+func (s *Server) AddDetections(metadata *pb.FrameMetadata, frame []byte, detections []*pb.BoundingBox) {
 	sourceName := "Detect"
+	sourceId, frameId := metadata.SourceId, metadata.FrameId
 
-	imgMat, err := gocv.IMDecode(frame, gocv.IMReadColor)
+	imgMat, err := codec.Decode(frame, metadata)
 	if err != nil {
 		log.Printf("Frame [%d], [%s]: Error decoding image: %v", frameId, sourceName, err)
 		return
@@ -51,83 +74,36 @@ func (s *Server) AddDetections(sourceId string, frameId int64, frame []byte, det
 	defer imgMat.Close()
 
 	// Check if the client already exists
+	s.mu.Lock()
 	trClient, found := s.Trackers[sourceId]
-
-	// The maximum number of detections to track: 200
-	matches := make(map[int]int, 200)
-	notMatched := []int{}
-
 	if !found {
-		trackerInstances := make([]*TrackerInstance, 0)
-		for _, bb := range detections {
-			trackerInstance := NewTrackerInstance(bb)
-			trackerInstance.InitTracker(imgMat)
-			trackerInstances = append(trackerInstances, trackerInstance)
-		}
 		trClient = &TrackerClient{
 			sourceId:        sourceId,
-			trackerInstance: trackerInstances,
+			trackerInstance: make([]*TrackerInstance, 0),
 		}
 		s.Trackers[sourceId] = trClient
-		log.Printf("Frame [%d], [%s]: Tracker added with (%d) boxes: [%s]", frameId, sourceName, len(trClient.trackerInstance), sourceId)
-
+	}
+	s.mu.Unlock()
+	if !found {
+		log.Printf("Frame [%d], [%s]: Tracker added for [%d] detections: [%s]", frameId, sourceName, len(detections), sourceId)
 	} else {
 		log.Printf("Frame [%d], [%s]: Tracker already exists, updating boxes [%s]", frameId, sourceName, sourceId)
-		// trClient, ok := trClientIf.(*TrackerClient)
-		// if !ok {
-		// 	log.Printf("Frame [%d], [%s]:Error: unable to cast tracker to expected type", frameId, sourceName)
-		// 	return
-		// }
-
-		trClient.mu.Lock()
-		defer trClient.mu.Unlock()
-
-		// Iterate over each detection
-		for i, bb := range detections {
-			iou := 0.0
-			for i2, bb2 := range trClient.trackerInstance {
-				iou2 := getIoU(bb, bb2.store)
-				// If IoU exceeds threshold and is the highest so far, register match
-				if iou2 > 0.5 && iou < iou2 {
-					iou = iou2
-					matches[i2] = i
-				}
-			}
-			// If detection didn't match with any object, add index to unmatched list
-			if iou == 0.0 {
-				notMatched = append(notMatched, i)
-			}
-			log.Printf("Frame [%d], [%s]: Detection [%d] IoU: %f", frameId, sourceName, i, iou)
-		}
-
-		log.Printf("Frame [%d], [%s]: Mathces: [%d], Unmatched: [%d]", frameId, sourceName, len(matches), len(notMatched))
-		// If the object is already being tracked, update its position
-		for i2, i := range matches {
-			trClient.DeleteInstanceAt(i2)
-
-			newTrackerInstance := NewTrackerInstance(detections[i])
-			newTrackerInstance.InitTracker(imgMat)
+	}
 
-			trClient.AddInstance(newTrackerInstance)
-		}
+	trClient.mu.Lock()
+	defer trClient.mu.Unlock()
 
-		// If the object is not being tracked, add a new tracker instance
-		for _, i := range notMatched {
-			newTrackerInstance := NewTrackerInstance(detections[i])
-			newTrackerInstance.InitTracker(imgMat)
-
-			trClient.AddInstance(newTrackerInstance)
-		}
-	}
+	s.Strategy.Update(trClient, imgMat, frameId, detections)
+	trClient.touch(frameId)
+	s.publish(trClient, metadata)
+	logTracks(frameId, sourceName, trClient.trackerInstance)
 
 	if s.DtConfig.SaveImage && frameId%int64(s.DtConfig.SaveImageFrequencyDt) == 0 {
 		timestamp := time.Now().UnixNano()
 		filename := fmt.Sprintf("%s/%d_detect.jpg", s.DtConfig.SaveImagePath, timestamp)
 		log.Printf("Frame [%d], [%s]: Saving image with [%d] detections as %d_detect.jpg",
 			frameId, sourceName, len(trClient.trackerInstance), timestamp)
-		for _, trInstance := range trClient.trackerInstance {
-			gocv.Rectangle(&imgMat, trInstance.store, color.RGBA{0, 255, 0, 0}, 2)
-		}
+		drawTracks(&imgMat, trClient.trackerInstance)
 		if ok := gocv.IMWrite(filename, imgMat); !ok {
 			log.Printf("Frame [%d], [%s]: Failed to write frame to file", frameId, sourceName)
 		}
@@ -135,54 +111,44 @@ func (s *Server) AddDetections(sourceId string, frameId int64, frame []byte, det
 
 }
 
-func (s *Server) TrackObjects(frame []byte, metadata *api.FrameMetadata) {
+// TrackObjects moves the tracks of the source to a frame without detections,
+// replay is set when the frame runs again after a late detection and its
+// result was published already
+func (s *Server) TrackObjects(frame []byte, metadata *pb.FrameMetadata, replay bool) {
 	sourceName := "Track"
 
-	imgMat, err := gocv.IMDecode(frame, gocv.IMReadColor)
+	imgMat, err := codec.Decode(frame, metadata)
 	if err != nil {
 		log.Printf("Frame [%d], [%s]: Error decoding image: %v", metadata.FrameId, sourceName, err)
 		return
 	}
 	defer imgMat.Close()
 
-	// trClientIf, found := s.Trackers.Load(metadata.SourceId)
+	s.mu.RLock()
 	trClient, found := s.Trackers[metadata.SourceId]
+	s.mu.RUnlock()
 	if !found {
 		log.Printf("Frame [%d], [%s]: Tracking not found.", metadata.FrameId, sourceName)
 		return
 	}
-	// trackerClient, ok := trClientIf.(*TrackerClient)
-	// if !ok {
-	// 	log.Printf("Error: unable to cast tracker to expected type\n")
-	// 	return
-	// }
-	// log.Printf("Updating tracker for [%d] client [%s]...\n", metadata.FrameId, metadata.SourceId)
-	// Iterate over each detection
 
 	trClient.mu.Lock()
 	defer trClient.mu.Unlock()
 
-	lostInstances := make([]int, 0)
-	for i, trInstance := range trClient.trackerInstance {
-		if ok := trInstance.UpdateTracker(imgMat); !ok {
-			lostInstances = append(lostInstances, i)
-			continue
-		}
-	}
-	log.Printf("Frame [%d], [%s]: Lost trackings: [%d/%d]", metadata.FrameId, sourceName, len(lostInstances), len(trClient.trackerInstance))
-
-	// Delete lost instances
-	for _, i := range lostInstances {
-		trClient.DeleteInstanceAt(i)
+	total := len(trClient.trackerInstance)
+	lostInstances := s.Strategy.Predict(trClient, imgMat, metadata.FrameId)
+	trClient.touch(metadata.FrameId)
+	if !replay {
+		s.publish(trClient, metadata)
 	}
+	log.Printf("Frame [%d], [%s]: Lost trackings: [%d/%d]", metadata.FrameId, sourceName, lostInstances, total)
+	logTracks(metadata.FrameId, sourceName, trClient.trackerInstance)
 
 	if s.DtConfig.SaveImage && metadata.FrameId%int64(s.DtConfig.SaveImageFrequencyTr) == 0 {
 		timestamp := time.Now().UnixNano()
 		filename := fmt.Sprintf("%s/%d_track.jpg", s.DtConfig.SaveImagePath, timestamp)
 		log.Printf("Frame [%d], [%s]: Saving image as %d_track.jpg", metadata.FrameId, sourceName, timestamp)
-		for _, trInstance := range trClient.trackerInstance {
-			gocv.Rectangle(&imgMat, trInstance.store, color.RGBA{0, 255, 0, 0}, 2)
-		}
+		drawTracks(&imgMat, trClient.trackerInstance)
 		if ok := gocv.IMWrite(filename, imgMat); !ok {
 			log.Printf("Frame [%d], [%s]: Failed to write frame to file", metadata.FrameId, sourceName)
 		}
@@ -190,26 +156,72 @@ func (s *Server) TrackObjects(frame []byte, metadata *api.FrameMetadata) {
 
 }
 
+// sequence applies the update of a frame once the frames of its source sent
+// before it have been applied
+func (s *Server) sequence(metadata *pb.FrameMetadata, detection bool, apply func(replay bool)) {
+	s.mu.Lock()
+	if s.sequencers == nil {
+		s.sequencers = make(map[string]*Sequencer)
+	}
+	sq, found := s.sequencers[metadata.SourceId]
+	if !found {
+		sq = NewSequencer(metadata.SourceId, s.DtConfig, s.Metric)
+		s.sequencers[metadata.SourceId] = sq
+	}
+	// the frame is submitted under s.mu so PruneSequencers cannot remove the
+	// sequencer in between, Submit does not wait for the frame to be applied
+	defer s.mu.Unlock()
+
+	sq.Submit(metadata.FrameId, metadata.PreviousFrameId, detection, apply)
+}
+
+// PruneSequencers removes the sequencers of the sources that sent no frame
+// for idle, checking every interval for the lifetime of the service
+func (s *Server) PruneSequencers(idle, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		for sourceId, sq := range s.sequencers {
+			if sq.expire(idle) {
+				delete(s.sequencers, sourceId)
+				log.Printf("Removed the sequencer of idle source [%s]", sourceId)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// publish hands the tracks of a processed frame to the result sinks,
+// trClient.mu must be held
+func (s *Server) publish(trClient *TrackerClient, metadata *pb.FrameMetadata) {
+	if s.Results != nil {
+		s.Results.Publish(trClient.result(metadata))
+	}
+}
+
 // SendFrameToServer handles incoming data from ingestion/aggregation services
 func (s *Server) SendFrameToServer(ctx context.Context, recData *pb.FrameData) (*pb.Ack, error) {
 	recTime := time.Now().Format(time.RFC3339Nano)
 
-	// unmarshal metadata into a struct
-	var metadata api.FrameMetadata
-	if err := json.Unmarshal([]byte(recData.Metadata), &metadata); err != nil {
-		log.Printf("Error unmarshalling metadata: %v", err)
-		return nil, err
+	metadata := recData.GetMetadata()
+	if metadata == nil {
+		log.Printf("Frame metadata is missing")
+		return nil, fmt.Errorf("frame metadata is missing")
 	}
 
 	log.Printf("Frame [%d], [%s]: Received: [%d] Bytes\n", metadata.FrameId, "Track", len(recData.FrameData))
 
-	go s.TrackObjects(recData.FrameData, &metadata)
+	s.sequence(metadata, false, func(replay bool) {
+		s.TrackObjects(recData.FrameData, metadata, replay)
+	})
 
 	ack := &pb.Ack{
 		Status:                "ok",
 		OriginalSentTimestamp: recData.SentTimestamp,
 		ReceivedTimestamp:     recTime,
 		AckSentTimestamp:      time.Now().Format(time.RFC3339Nano),
+		Health:                s.Health(metadata.SourceId),
 	}
 
 	return ack, nil
@@ -219,25 +231,65 @@ func (s *Server) SendFrameToServer(ctx context.Context, recData *pb.FrameData) (
 func (s *Server) SendDetectedFrameToServer(ctx context.Context, recData *pb.FrameData) (*pb.Ack, error) {
 	recTime := time.Now().Format(time.RFC3339Nano)
 
-	// unmarshal metadata into a struct
-	var metadata detectionData
-	if err := json.Unmarshal([]byte(recData.Metadata), &metadata); err != nil {
-		log.Printf("Error unmarshalling metadata: %v", err)
-		return nil, err
+	metadata := recData.GetMetadata()
+	if metadata == nil {
+		log.Printf("Frame metadata is missing")
+		return nil, fmt.Errorf("frame metadata is missing")
 	}
 
-	log.Printf("Frame [%d], [%s]: Received: [%d] Bytes, Detections: [%d]", metadata.FrameId, "Detect", len(recData.FrameData), len(metadata.Boxes))
+	detections := recData.GetDetection().GetBoxes()
+
+	log.Printf("Frame [%d], [%s]: Received: [%d] Bytes, Detections: [%d]", metadata.FrameId, "Detect", len(recData.FrameData), len(detections))
 
-	// Go routine for adding/updating the detection data and managing the
-	// tracker instances
-	go s.AddDetections(metadata.SourceId, metadata.FrameId, recData.FrameData, metadata.Boxes)
+	// Adding/updating the detection data and managing the tracker instances
+	// happens in frame order with the tracking frames of the source
+	s.sequence(metadata, true, func(bool) {
+		s.AddDetections(metadata, recData.FrameData, detections)
+	})
 
 	ack := &pb.Ack{
 		Status:                "ok",
 		OriginalSentTimestamp: recData.SentTimestamp,
 		ReceivedTimestamp:     recTime,
 		AckSentTimestamp:      time.Now().Format(time.RFC3339Nano),
+		Health:                s.Health(metadata.SourceId),
 	}
 
 	return ack, nil
 }
+
+// StreamFrames handles a long-lived stream of frames from the aggregator and
+// acknowledges each frame with its source and frame id. Frames carrying
+// detections are the ones the aggregator forwards from the detector.
+func (s *Server) StreamFrames(stream pb.DetectionTrackingPipeline_StreamFramesServer) error {
+	for {
+		recData, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("Error receiving from frame stream: %v", err)
+			return err
+		}
+
+		var ack *pb.Ack
+		if recData.GetDetection() != nil {
+			ack, err = s.SendDetectedFrameToServer(stream.Context(), recData)
+		} else {
+			ack, err = s.SendFrameToServer(stream.Context(), recData)
+		}
+		if err != nil {
+			ack = &pb.Ack{
+				Status:                fmt.Sprintf("error: %v", err),
+				OriginalSentTimestamp: recData.SentTimestamp,
+			}
+		}
+		ack.SourceId = recData.GetMetadata().GetSourceId()
+		ack.FrameId = recData.GetMetadata().GetFrameId()
+
+		if err := stream.Send(ack); err != nil {
+			log.Printf("Frame [%d]: Error sending ack: %v", ack.FrameId, err)
+			return err
+		}
+	}
+}